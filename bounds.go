@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/math32"
+)
+
+// BoundsColoring picks what the color of a node's bounds shows
+type BoundsColoring int
+
+const (
+	BoundsByDepth BoundsColoring = iota
+	BoundsByMaterial
+)
+
+// BoundsMesh outlines the boxes of the non-empty nodes of the tree rooted at n, so merged nodes show as
+// larger boxes among the unit ones, with WireframeMaterials picked by depth or by material
+func (n *Node) BoundsMesh(coloring BoundsColoring) core.INode {
+	builders := make([]GeometryBuilder, len(WireframeMaterials))
+	n.DFS(func(node *Node, depth int) bool {
+		if node.empty() {
+			return false
+		}
+		color := depth
+		if coloring == BoundsByMaterial {
+			color = node.Material
+		}
+		g := &builders[color%len(builders)]
+		base := g.CurrentTriangleIndex()
+		h := node.Size / 2
+		for _, c := range cubeCorners {
+			d := [3]float32{float32(2*c[0] - 1), float32(2*c[1] - 1), float32(2*c[2] - 1)}
+			g.AddVertex(node.Position.X+d[0]*h, node.Position.Y+d[1]*h, node.Position.Z+d[2]*h)
+			normal := math32.Vector3{X: d[0], Y: d[1], Z: d[2]}
+			normal.Normalize()
+			g.AddNormal(normal.X, normal.Y, normal.Z)
+		}
+		for _, e := range cubeEdges {
+			g.AddLine(base+uint32(e[0]), base+uint32(e[1]))
+		}
+		return true
+	})
+	root := core.NewNode()
+	for i := range builders {
+		if len(builders[i].indices) > 0 {
+			root.Add(graphic.NewLines(builders[i].Build(), WireframeMaterials[i]))
+		}
+	}
+	return root
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/graphic"
+)
+
+func TestBoundsMesh(t *testing.T) {
+	tree := sphereTree(16, 4)
+	tree.Set(1, 1, 1, 3, 1)
+	tree.merge()
+	nodes := 0
+	materials := map[int]bool{}
+	tree.DFS(func(n *Node, _ int) bool {
+		if n.empty() {
+			return false
+		}
+		nodes++
+		materials[n.Material] = true
+		return true
+	})
+
+	for _, coloring := range []BoundsColoring{BoundsByDepth, BoundsByMaterial} {
+		lines := 0
+		children := tree.BoundsMesh(coloring).GetNode().Children()
+		for _, child := range children {
+			lines += len(child.(*graphic.Lines).GetGeometry().Indices()) / 2
+		}
+		if lines != 12*nodes {
+			t.Fatalf("coloring %d drew %d lines for %d nodes", coloring, lines, nodes)
+		}
+		if coloring == BoundsByMaterial && len(children) != len(materials) {
+			t.Fatalf("colored by material with %d colors, want %d", len(children), len(materials))
+		}
+	}
+}
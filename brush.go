@@ -0,0 +1,222 @@
+package main
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+// A Shape is a solid given by its signed distance, negative inside, in the coordinates of the tree it edits
+type Shape interface {
+	Distance(p math32.Vector3) float32
+	// Bounds contains every point with negative distance
+	Bounds() math32.Box3
+}
+
+type Sphere struct {
+	Center math32.Vector3
+	Radius float32
+}
+
+func (s Sphere) Distance(p math32.Vector3) float32 {
+	return p.DistanceTo(&s.Center) - s.Radius
+}
+
+func (s Sphere) Bounds() math32.Box3 {
+	r := math32.Vector3{X: s.Radius, Y: s.Radius, Z: s.Radius}
+	return math32.Box3{Min: *s.Center.Clone().Sub(&r), Max: *s.Center.Clone().Add(&r)}
+}
+
+// A Box is axis aligned, with HalfSize the distance from its center to its faces
+type Box struct {
+	Center   math32.Vector3
+	HalfSize math32.Vector3
+}
+
+func (b Box) Distance(p math32.Vector3) float32 {
+	q := p.Sub(&b.Center)
+	q.Set(math32.Abs(q.X)-b.HalfSize.X, math32.Abs(q.Y)-b.HalfSize.Y, math32.Abs(q.Z)-b.HalfSize.Z)
+	inside := math32.Min(math32.Max(q.X, math32.Max(q.Y, q.Z)), 0)
+	outside := math32.Vector3{X: math32.Max(q.X, 0), Y: math32.Max(q.Y, 0), Z: math32.Max(q.Z, 0)}
+	return outside.Length() + inside
+}
+
+func (b Box) Bounds() math32.Box3 {
+	return math32.Box3{Min: *b.Center.Clone().Sub(&b.HalfSize), Max: *b.Center.Clone().Add(&b.HalfSize)}
+}
+
+// A Cylinder stands upright along y, centered halfway up its Height
+type Cylinder struct {
+	Center math32.Vector3
+	Radius float32
+	Height float32
+}
+
+func (c Cylinder) Distance(p math32.Vector3) float32 {
+	dx, dz := p.X-c.Center.X, p.Z-c.Center.Z
+	r := math32.Sqrt(dx*dx+dz*dz) - c.Radius
+	h := math32.Abs(p.Y-c.Center.Y) - c.Height/2
+	inside := math32.Min(math32.Max(r, h), 0)
+	r, h = math32.Max(r, 0), math32.Max(h, 0)
+	return math32.Sqrt(r*r+h*h) + inside
+}
+
+func (c Cylinder) Bounds() math32.Box3 {
+	e := math32.Vector3{X: c.Radius, Y: c.Height / 2, Z: c.Radius}
+	return math32.Box3{Min: *c.Center.Clone().Sub(&e), Max: *c.Center.Clone().Add(&e)}
+}
+
+// A Capsule is the set of points within Radius of the segment from A to B
+type Capsule struct {
+	A, B   math32.Vector3
+	Radius float32
+}
+
+func (c Capsule) Distance(p math32.Vector3) float32 {
+	ab := c.B.Clone().Sub(&c.A)
+	ap := p.Clone().Sub(&c.A)
+	t := float32(0)
+	if l := ab.LengthSq(); l > 0 {
+		t = math32.Clamp(ap.Dot(ab)/l, 0, 1)
+	}
+	closest := ab.MultiplyScalar(t).Add(&c.A)
+	return p.DistanceTo(closest) - c.Radius
+}
+
+func (c Capsule) Bounds() math32.Box3 {
+	b := math32.Box3{Min: c.A, Max: c.A}
+	b.ExpandByPoint(&c.B)
+	b.ExpandByScalar(c.Radius)
+	return b
+}
+
+// A BrushMode says how an edit combines a shape with the tree
+type BrushMode int
+
+const (
+	// Union fills the shape with material
+	Union BrushMode = iota
+	// Subtract carves the shape out
+	Subtract
+	// Paint changes the material of the solid cells inside the shape, leaving densities alone
+	Paint
+)
+
+// Edit applies shape to the tree in the given mode, splitting merged nodes it touches, then pruning
+// and merging the edited region again afterwards, and returns the root of the tree
+// Densities fall off linearly across the shape's boundary, crossing the isolevel on it, so the
+// meshers that interpolate place the surface on the shape instead of on the cell grid
+// A union reaching outside the tree grows it first
+func (n *Node) Edit(shape Shape, mode BrushMode, material int) *Node {
+	bounds := shape.Bounds()
+	n = n.Root()
+	if mode == Union {
+		// The falloff reaches the cells whose centers are less than half a cell beyond the shape
+		o := n.origin()
+		var lo, hi [3]float32
+		for axis := 0; axis < 3; axis++ {
+			c := o.Component(axis)
+			lo[axis] = c + math32.Floor(bounds.Min.Component(axis)-isoLevel-c) + 1
+			hi[axis] = c + math32.Ceil(bounds.Max.Component(axis)+isoLevel-c) - 1
+		}
+		n = n.Grow(lo[0], lo[1], lo[2])
+		n = n.Grow(hi[0], hi[1], hi[2])
+	}
+	min, max, ok := n.cellRange(bounds)
+	if !ok {
+		return n
+	}
+	origin := n.origin()
+	changed := false
+	for x := min[0]; x <= max[0]; x++ {
+		for y := min[1]; y <= max[1]; y++ {
+			for z := min[2]; z <= max[2]; z++ {
+				p := [3]int{x, y, z}
+				pos := samplePosition(origin, p)
+				coverage := math32.Clamp(isoLevel-shape.Distance(pos), 0, 1)
+				if coverage == 0 {
+					continue
+				}
+				old := n.leafAt(p)
+				oldDensity := float32(0)
+				if !old.empty() {
+					oldDensity = old.Density
+				}
+				switch mode {
+				case Union:
+					if coverage <= oldDensity {
+						continue
+					}
+					node := n.At(pos.X, pos.Y, pos.Z)
+					node.Material = material
+					node.Density = coverage
+				case Subtract:
+					density := math32.Min(oldDensity, 1-coverage)
+					if density == oldDensity {
+						continue
+					}
+					node := n.At(pos.X, pos.Y, pos.Z)
+					node.Density = density
+					if density == 0 {
+						node.Material = 0
+					}
+				case Paint:
+					if coverage < isoLevel || oldDensity == 0 || old.Material == material {
+						continue
+					}
+					n.At(pos.X, pos.Y, pos.Z).Material = material
+				}
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return n
+	}
+	if mode != Paint {
+		n.updateHermiteRange(min, max)
+	}
+	e := n.enclosing(min, max)
+	e.Prune()
+	e.merge()
+	return n
+}
+
+// cellRange returns the unit cells whose centers are within one cell of the box, clipped to the tree,
+// which includes every cell an edit's falloff reaches
+func (n *Node) cellRange(b math32.Box3) (min, max [3]int, ok bool) {
+	origin := n.origin()
+	size := int(n.Size)
+	for axis := 0; axis < 3; axis++ {
+		o := origin.Component(axis)
+		min[axis] = int(math32.Max(math32.Ceil(b.Min.Component(axis)-1-o), 0))
+		max[axis] = int(math32.Min(math32.Floor(b.Max.Component(axis)+1-o), float32(size-1)))
+		if min[axis] > max[axis] {
+			return min, max, false
+		}
+	}
+	return min, max, true
+}
+
+// enclosing returns the smallest existing node covering the unit cells from min to max
+func (n *Node) enclosing(min, max [3]int) *Node {
+	node := n
+	size := int(n.Size)
+	for {
+		h := size / 2
+		i := 0
+		for axis := 0; axis < 3; axis++ {
+			lo, hi := min[axis] >= h, max[axis] >= h
+			if lo != hi {
+				return node
+			}
+			if lo {
+				min[axis] -= h
+				max[axis] -= h
+				i |= 4 >> axis
+			}
+		}
+		if h < 1 || node.Children[i] == nil {
+			return node
+		}
+		node, size = node.Children[i], h
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestShapes(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		shape Shape
+		p     math32.Vector3
+		want  float32
+	}{
+		{"sphere inside", Sphere{Center: math32.Vector3{X: 1}, Radius: 2}, math32.Vector3{X: 1}, -2},
+		{"sphere outside", Sphere{Center: math32.Vector3{X: 1}, Radius: 2}, math32.Vector3{X: 4}, 1},
+		{"box face", Box{HalfSize: math32.Vector3{X: 1, Y: 2, Z: 3}}, math32.Vector3{Y: 3}, 1},
+		{"box corner", Box{HalfSize: math32.Vector3{X: 1, Y: 1, Z: 1}}, math32.Vector3{X: 4, Y: 5, Z: 1}, 5},
+		{"box inside", Box{HalfSize: math32.Vector3{X: 1, Y: 2, Z: 3}}, math32.Vector3{Y: 1.5}, -0.5},
+		{"cylinder side", Cylinder{Radius: 2, Height: 4}, math32.Vector3{X: 3, Y: 1}, 1},
+		{"cylinder top", Cylinder{Radius: 2, Height: 4}, math32.Vector3{Z: 1, Y: 5}, 3},
+		{"cylinder rim", Cylinder{Radius: 2, Height: 4}, math32.Vector3{X: 5, Y: 6}, 5},
+		{"capsule middle", Capsule{B: math32.Vector3{Y: 4}, Radius: 1}, math32.Vector3{X: 3, Y: 2}, 2},
+		{"capsule end", Capsule{B: math32.Vector3{Y: 4}, Radius: 1}, math32.Vector3{Y: -2}, 1},
+	} {
+		if got := test.shape.Distance(test.p); math32.Abs(got-test.want) > 1e-5 {
+			t.Fatalf("%s: got distance %.2f, want %.2f", test.name, got, test.want)
+		}
+		b := test.shape.Bounds()
+		if got := test.shape.Distance(*b.Max.Clone().Add(&math32.Vector3{X: 0.01, Y: 0.01, Z: 0.01})); got < 0 {
+			t.Fatalf("%s: point beyond bounds is inside", test.name)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 8, Y: 8, Z: 8}, 16)
+	cell := func(x, y, z int) *Node {
+		return tree.leafAt([3]int{x, y, z})
+	}
+	checkHermite := func(name string) {
+		if got, want := crossings(tree), computedCrossings(tree); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: stored %d crossings differ from the %d computed", name, len(got), len(want))
+		}
+	}
+
+	// Filling the octant at the origin merges it into one node
+	tree.Edit(Box{Center: math32.Vector3{X: 4, Y: 4, Z: 4}, HalfSize: math32.Vector3{X: 4, Y: 4, Z: 4}}, Union, 1)
+	if n := cell(0, 0, 0); n == nil || n.Size != 8 || n.Material != 1 || n.Density != 1 {
+		t.Fatalf("box was not merged, got %v", n)
+	}
+	if n := cell(8, 3, 3); !n.empty() {
+		t.Fatalf("box spilled into cell outside it, got %v", n)
+	}
+	checkHermite("box")
+
+	// Digging into the merged octant splits it
+	tree.Edit(Sphere{Center: math32.Vector3{X: 8, Y: 8, Z: 8}, Radius: 3}, Subtract, 0)
+	if n := cell(7, 7, 7); !n.empty() {
+		t.Fatalf("cell inside dug sphere is not empty, got %v", n)
+	}
+	if n := cell(0, 0, 0); n == nil || n.Size != 4 || n.Density != 1 {
+		t.Fatalf("untouched part of the octant did not merge again, got %v", n)
+	}
+	// The center of this cell is 0.4 inside the sphere, the density falls to the isolevel on its surface
+	if n, want := cell(7, 7, 5), 1-(isoLevel-(math32.Sqrt(6.75)-3)); n == nil || math32.Abs(n.Density-want) > 1e-5 {
+		t.Fatalf("cell near the dug surface got %v, want density %.2f", n, want)
+	}
+	checkHermite("subtract")
+	tree.DFS(func(n *Node, _ int) bool {
+		if n.Children == [8]*Node{} && n.empty() && len(n.Hermite) == 0 && n != tree {
+			t.Fatalf("digging left bare node %v", n)
+		}
+		return true
+	})
+
+	// Building a capsule over empty space and the box
+	tree.Edit(Capsule{A: math32.Vector3{X: 2, Y: 8.5, Z: 2}, B: math32.Vector3{X: 12, Y: 8.5, Z: 2}, Radius: 1.5}, Union, 2)
+	if n := cell(10, 8, 1); n == nil || n.Material != 2 || n.Density != 1 {
+		t.Fatalf("capsule cell got %v", n)
+	}
+	if n := cell(4, 7, 1); n == nil || n.Material != 1 || n.Density != 1 {
+		t.Fatalf("union changed solid box cell, got %v", n)
+	}
+	checkHermite("capsule")
+
+	// Painting leaves densities alone
+	before := computedCrossings(tree)
+	tree.Edit(Cylinder{Center: math32.Vector3{X: 2, Y: 2, Z: 2}, Radius: 1, Height: 2}, Paint, 3)
+	if n := cell(1, 1, 1); n == nil || n.Material != 3 || n.Density != 1 {
+		t.Fatalf("painted cell got %v", n)
+	}
+	if n := cell(4, 1, 1); n == nil || n.Material != 1 {
+		t.Fatalf("cell outside paint got %v", n)
+	}
+	if n := cell(1, 12, 1); !n.empty() {
+		t.Fatalf("paint filled an empty cell, got %v", n)
+	}
+	if after := computedCrossings(tree); !reflect.DeepEqual(before, after) {
+		t.Fatalf("painting moved the surface")
+	}
+	checkHermite("paint")
+}
+
+func TestEditGrows(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	if root := tree.Edit(Sphere{Center: math32.Vector3{X: 4, Y: 4, Z: 4}, Radius: 10}, Subtract, 0); root != tree {
+		t.Fatalf("subtract grew the tree to %v", root)
+	}
+	root := tree.Edit(Sphere{Center: math32.Vector3{X: 9, Y: 4, Z: 4}, Radius: 2}, Union, 1)
+	if root == tree || root.Root() != root || tree.Root() != root {
+		t.Fatalf("union outside did not grow the tree")
+	}
+	if n := root.Lookup(10.5, 4.5, 4.5); n.empty() || n.Material != 1 {
+		t.Fatalf("cell outside the old tree got %v", n)
+	}
+	if n := root.Lookup(7.5, 4.5, 4.5); n.empty() || n.Material != 1 {
+		t.Fatalf("cell inside the old tree got %v", n)
+	}
+	if got, want := crossings(root), computedCrossings(root); !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored %d crossings differ from the %d computed", len(got), len(want))
+	}
+}
+
+func TestEditFalloffIsNotSolid(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 8, Y: 8, Z: 8}, 16)
+	sphere := Sphere{Center: math32.Vector3{X: 8, Y: 8, Z: 8}, Radius: 3}
+	tree.Edit(sphere, Union, 1)
+	// Blocks are the cells whose centers the shape covers, its falloff around them is not
+	origin := tree.origin()
+	falloff := 0
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			for z := 0; z < 16; z++ {
+				p := [3]int{x, y, z}
+				inside := sphere.Distance(samplePosition(origin, p)) <= 0
+				if m, d := tree.Get(x, y, z); m != 0 && d > 0 && !inside {
+					falloff++
+				}
+				if block := volumeMaterial(tree, p) != 0; block != inside {
+					t.Fatalf("cell %v is a block %t, inside %t", p, block, inside)
+				}
+			}
+		}
+	}
+	if falloff == 0 {
+		t.Fatalf("union left no falloff")
+	}
+	hit, ok := tree.Raycast(math32.Vector3{X: 0.2, Y: 8.3, Z: 8.1}, math32.Vector3{X: 1}, 16)
+	if !ok || sphere.Distance(hit.Node.Position) > 0 {
+		t.Fatalf("ray hit %v outside the sphere", hit.Node)
+	}
+}
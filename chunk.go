@@ -5,8 +5,6 @@ import (
 
 	"github.com/g3n/engine/geometry"
 	"github.com/g3n/engine/gls"
-	"github.com/g3n/engine/graphic"
-	"github.com/g3n/engine/material"
 	"github.com/g3n/engine/math32"
 )
 
@@ -21,7 +19,9 @@ const (
 const ChunkSize = 32
 
 type Chunk struct {
-	data [ChunkSize][ChunkSize][ChunkSize]int
+	// The material of each block, and how much of it is filled as a Node's density
+	data    [ChunkSize][ChunkSize][ChunkSize]int
+	density [ChunkSize][ChunkSize][ChunkSize]float32
 }
 
 func NewChunk() *Chunk {
@@ -42,23 +42,145 @@ func NewChunk() *Chunk {
 				if y == 3 && rand.Float32() < 0.2 {
 					c.data[x][y][z] = Water
 				}
+				c.density[x][y][z] = 1
 			}
 		}
 	}
 	return c
 }
 
+// Get returns the material and density of block (x, y, z), or zeros if it is empty or outside the chunk
+func (c *Chunk) Get(x, y, z int) (material int, density float32) {
+	if x < 0 || y < 0 || z < 0 || x >= ChunkSize || y >= ChunkSize || z >= ChunkSize {
+		return 0, 0
+	}
+	if c.data[x][y][z] == 0 || c.density[x][y][z] == 0 {
+		return 0, 0
+	}
+	return c.data[x][y][z], c.density[x][y][z]
+}
+
+// Set sets the material and density of block (x, y, z), ignoring blocks outside the chunk
+func (c *Chunk) Set(x, y, z int, material int, density float32) {
+	if x < 0 || y < 0 || z < 0 || x >= ChunkSize || y >= ChunkSize || z >= ChunkSize {
+		return
+	}
+	c.data[x][y][z] = material
+	c.density[x][y][z] = density
+}
+
+// Tree converts the chunk to a tree of its size with block (x, y, z) as unit cell (x, y, z), so meshes
+// of both are in the same place, see VolumeTree
+func (c *Chunk) Tree() *Node {
+	return VolumeTree(c)
+}
+
+// Chunk copies the unit cells of the tree from cell (x, y, z) on into a chunk, cells outside the tree being empty
+func (n *Node) Chunk(x, y, z int) *Chunk {
+	c := new(Chunk)
+	for i := 0; i < ChunkSize; i++ {
+		for j := 0; j < ChunkSize; j++ {
+			for k := 0; k < ChunkSize; k++ {
+				if material, density := n.Get(x+i, y+j, z+k); material != 0 && density != 0 {
+					c.Set(i, j, k, material, density)
+				}
+			}
+		}
+	}
+	return c
+}
+
+// Bounds returns the box the chunk's blocks fill, from the origin, making the chunk a Volume
+func (c *Chunk) Bounds() math32.Box3 {
+	return math32.Box3{Max: math32.Vector3{X: ChunkSize, Y: ChunkSize, Z: ChunkSize}}
+}
+
+// Cells calls fn with every non-empty block
+func (c *Chunk) Cells(fn func(p [3]int, material int, density float32)) {
+	for x := 0; x < ChunkSize; x++ {
+		for y := 0; y < ChunkSize; y++ {
+			for z := 0; z < ChunkSize; z++ {
+				if material, density := c.Get(x, y, z); material != 0 {
+					fn([3]int{x, y, z}, material, density)
+				}
+			}
+		}
+	}
+}
+
+// A ChunkGrid tiles chunks into a world, keyed by their position in chunks
+type ChunkGrid map[[3]int]*Chunk
+
+// Neighbors returns the chunks sharing a face with the one at p, in the order of FaceDirections,
+// nil where there is none
+func (g ChunkGrid) Neighbors(p [3]int) [6]*Chunk {
+	var n [6]*Chunk
+	for i, d := range FaceDirections {
+		n[i] = g[[3]int{p[0] + d[0], p[1] + d[1], p[2] + d[2]}]
+	}
+	return n
+}
+
+// WithNeighbors returns the chunk as a Volume that sees the blocks of its neighbors, given in the order of
+// FaceDirections and nil where there is none, beyond its faces
+// Meshing it draws the chunk's blocks only, but hides their faces against neighboring blocks as well,
+// so tiled chunks don't draw the faces between them
+func (c *Chunk) WithNeighbors(neighbors [6]*Chunk) Volume {
+	return borderedChunk{c, neighbors}
+}
+
+type borderedChunk struct {
+	*Chunk
+	neighbors [6]*Chunk
+}
+
+// Get returns the material and density of block (x, y, z) of the chunk, or of a neighbor across one of its faces
+func (b borderedChunk) Get(x, y, z int) (material int, density float32) {
+	p := [3]int{x, y, z}
+	face := -1
+	for axis := 0; axis < 3; axis++ {
+		if p[axis] >= 0 && p[axis] < ChunkSize {
+			continue
+		}
+		// Blocks beyond an edge or a corner are not across a face
+		if face >= 0 {
+			return 0, 0
+		}
+		if p[axis] < 0 {
+			p[axis] += ChunkSize
+			face = 2 * axis
+		} else {
+			p[axis] -= ChunkSize
+			face = 2*axis + 1
+		}
+	}
+	if face < 0 {
+		return b.Chunk.Get(x, y, z)
+	}
+	if b.neighbors[face] == nil {
+		return 0, 0
+	}
+	return b.neighbors[face].Get(p[0], p[1], p[2])
+}
+
 func (c *Chunk) SimpleGeom() geometry.IGeometry {
+	return SimpleGeom(c)
+}
+
+// SimpleGeom draws all six faces of every cell of the volume inside the surface, as a block
+func SimpleGeom(v Volume) geometry.IGeometry {
 	var positions []float32
 	var uvs []float32
 	var indices []uint32
-	for x := 0; x < ChunkSize; x++ {
-		for y := 0; y < ChunkSize; y++ {
-			for z := 0; z < ChunkSize; z++ {
-				if c.data[x][y][z] != 0 {
-					xf := float32(x)
-					yf := float32(y)
-					zf := float32(z)
+	size := volumeSize(v)
+	min := v.Bounds().Min
+	for x := 0; x < size[0]; x++ {
+		for y := 0; y < size[1]; y++ {
+			for z := 0; z < size[2]; z++ {
+				if volumeMaterial(v, [3]int{x, y, z}) != 0 {
+					xf := min.X + float32(x)
+					yf := min.Y + float32(y)
+					zf := min.Z + float32(z)
 
 					i := uint32(len(positions) / 3)
 					// 0, 0, 0
@@ -155,24 +277,32 @@ func (c *Chunk) SimpleGeom() geometry.IGeometry {
 	return geom
 }
 
-func (c *Chunk) interior(x, y, z int) bool {
-	return z < 31 && c.data[x][y][z+1] != 0 &&
-		z > 0 && c.data[x][y][z-1] != 0 &&
-		y < 31 && c.data[x][y+1][z] != 0 &&
-		y > 0 && c.data[x][y-1][z] != 0 &&
-		x < 31 && c.data[x+1][y][z] != 0 &&
-		x > 0 && c.data[x-1][y][z] != 0
+// interior reports whether all six neighbors of cell (x, y, z) of the volume are blocks
+func interior(v Volume, x, y, z int) bool {
+	return volumeMaterial(v, [3]int{x, y, z + 1}) != 0 &&
+		volumeMaterial(v, [3]int{x, y, z - 1}) != 0 &&
+		volumeMaterial(v, [3]int{x, y + 1, z}) != 0 &&
+		volumeMaterial(v, [3]int{x, y - 1, z}) != 0 &&
+		volumeMaterial(v, [3]int{x + 1, y, z}) != 0 &&
+		volumeMaterial(v, [3]int{x - 1, y, z}) != 0
 }
 
 func (c *Chunk) CulledGeom() geometry.IGeometry {
+	return CulledGeom(c)
+}
+
+// CulledGeom draws the cells of the volume like SimpleGeom, leaving out those hidden on every side
+func CulledGeom(v Volume) geometry.IGeometry {
 	g := &GeometryBuilder{}
-	for x := 0; x < ChunkSize; x++ {
-		for y := 0; y < ChunkSize; y++ {
-			for z := 0; z < ChunkSize; z++ {
-				if c.data[x][y][z] != 0 && !c.interior(x, y, z) {
-					xf := float32(x)
-					yf := float32(y)
-					zf := float32(z)
+	size := volumeSize(v)
+	min := v.Bounds().Min
+	for x := 0; x < size[0]; x++ {
+		for y := 0; y < size[1]; y++ {
+			for z := 0; z < size[2]; z++ {
+				if volumeMaterial(v, [3]int{x, y, z}) != 0 && !interior(v, x, y, z) {
+					xf := min.X + float32(x)
+					yf := min.Y + float32(y)
+					zf := min.Z + float32(z)
 
 					i := g.CurrentTriangleIndex()
 					// 0, 0, 0
@@ -223,7 +353,22 @@ func (c *Chunk) CulledGeom() geometry.IGeometry {
 }
 
 func (c *Chunk) GreedyGeom() geometry.IGeometry {
+	return GreedyGeom(c)
+}
+
+// GreedyGeom draws the faces between the volume's blocks, its cells inside the surface, and the cells that aren't,
+// those outside it included, joining neighboring faces of the same material facing the same way into as few
+// rectangles as it can
+// Faces face away from their block, and carry its material for the terrain shader
+func GreedyGeom(vol Volume) geometry.IGeometry {
 	g := &GeometryBuilder{}
+	greedy(g, vol)
+	return g.Build()
+}
+
+func greedy(g *GeometryBuilder, vol Volume) {
+	size := volumeSize(vol)
+	min := vol.Bounds().Min
 	// Sweep over each axis (X, Y and Z)
 	for d := 0; d < 3; d++ {
 		u := (d + 1) % 3
@@ -231,24 +376,29 @@ func (c *Chunk) GreedyGeom() geometry.IGeometry {
 		var x [3]int
 		var q [3]int
 
-		var mask [ChunkSize * ChunkSize]bool
+		mask := make([]int, size[u]*size[v])
 		q[d] = 1
 
-		// Check each slice of the chunk one at a time
-		for x[d] = -1; x[d] < ChunkSize; {
+		// Check each slice of the volume one at a time
+		for x[d] = -1; x[d] < size[d]; {
 			// Compute the mask
 			n := 0
-			for x[v] = 0; x[v] < ChunkSize; x[v]++ {
-				for x[u] = 0; x[u] < ChunkSize; x[u]++ {
+			for x[v] = 0; x[v] < size[v]; x[v]++ {
+				for x[u] = 0; x[u] < size[u]; x[u]++ {
 					// q determines the direction (X, Y or Z) that we are searching
-					// The mask is set to true if there is a visible face between two blocks,
-					//   i.e. both aren't empty and both aren't blocks
-					if x[0] < 0 || x[1] < 0 || x[2] < 0 || x[0] >= ChunkSize || x[1] >= ChunkSize || x[2] >= ChunkSize || x[0]+q[0] < 0 || x[1]+q[1] < 0 || x[2]+q[2] < 0 || x[0]+q[0] >= ChunkSize || x[1]+q[1] >= ChunkSize || x[2]+q[2] >= ChunkSize {
-						mask[n] = true
-					} else {
-						blockCurrent := c.data[x[0]][x[1]][x[2]]
-						blockCompare := c.data[x[0]+q[0]][x[1]+q[1]][x[2]+q[2]]
-						mask[n] = blockCurrent != blockCompare
+					// The mask is set if there is a visible face between two blocks, i.e. one is empty
+					//   and the other isn't, to the material of the solid one, negated if it is the one
+					//   along q so that the face is turned the other way
+					blockCurrent := volumeMaterial(vol, x)
+					blockCompare := volumeMaterial(vol, [3]int{x[0] + q[0], x[1] + q[1], x[2] + q[2]})
+					//   Faces of blocks outside the volume, which it may see beyond its bounds, are left out
+					switch {
+					case blockCurrent != 0 && blockCompare == 0 && x[d] >= 0:
+						mask[n] = blockCurrent
+					case blockCurrent == 0 && blockCompare != 0 && x[d]+1 < size[d]:
+						mask[n] = -blockCompare
+					default:
+						mask[n] = 0
 					}
 					n++
 				}
@@ -259,14 +409,14 @@ func (c *Chunk) GreedyGeom() geometry.IGeometry {
 			n = 0
 
 			// Generate a mesh from the mask using lexicographic ordering,
-			//   by looping over each block in this slice of the chunk
-			for j := 0; j < ChunkSize; j++ {
-				for i := 0; i < ChunkSize; {
-					if mask[n] {
+			//   by looping over each block in this slice of the volume
+			for j := 0; j < size[v]; j++ {
+				for i := 0; i < size[u]; {
+					if face := mask[n]; face != 0 {
 						w, h := 1, 1
 						// Compute the width of this quad and store it in w
-						//   This is done by searching along the current axis until mask[n + w] is false
-						for ; i+w < ChunkSize && mask[n+w]; w++ {
+						//   This is done by searching along the current axis until mask[n + w] is another face
+						for ; i+w < size[u] && mask[n+w] == face; w++ {
 						}
 
 						// Compute the height of this quad and store it in h
@@ -274,15 +424,18 @@ func (c *Chunk) GreedyGeom() geometry.IGeometry {
 						//   For example, if w is 5 we currently have a quad of dimensions 1 x 5. To reduce triangle count,
 						//   greedy meshing will attempt to expand this quad out to CHUNK_SIZE x 5, but will stop if it reaches a hole in the mask
 
-						for hole := false; j+h < ChunkSize && !hole; h++ {
+						for hole := false; j+h < size[v]; h++ {
 							// Check each block next to this quad
 							for k := 0; k < w; k++ {
-								// If there's a hole in the mask, exit
-								if !mask[n+k+h*ChunkSize] {
+								// If there's a hole in the mask, exit without taking this row
+								if mask[n+k+h*size[u]] != face {
 									hole = true
 									break
 								}
 							}
+							if hole {
+								break
+							}
 						}
 
 						x[u] = i
@@ -296,17 +449,28 @@ func (c *Chunk) GreedyGeom() geometry.IGeometry {
 						dv[v] = h
 
 						ti := g.CurrentTriangleIndex()
-						g.AddVertex(float32(x[0]), float32(x[1]), float32(x[2]))
-						g.AddVertex(float32(x[0]+du[0]), float32(x[1]+du[1]), float32(x[2]+du[2]))
-						g.AddVertex(float32(x[0]+dv[0]), float32(x[1]+dv[1]), float32(x[2]+dv[2]))
-						g.AddVertex(float32(x[0]+du[0]+dv[0]), float32(x[1]+du[1]+dv[1]), float32(x[2]+du[2]+dv[2]))
-						g.AddTriangle(ti+0, ti+2, ti+1)
-						g.AddTriangle(ti+1, ti+2, ti+3)
+						g.AddVertex(min.X+float32(x[0]), min.Y+float32(x[1]), min.Z+float32(x[2]))
+						g.AddVertex(min.X+float32(x[0]+du[0]), min.Y+float32(x[1]+du[1]), min.Z+float32(x[2]+du[2]))
+						g.AddVertex(min.X+float32(x[0]+dv[0]), min.Y+float32(x[1]+dv[1]), min.Z+float32(x[2]+dv[2]))
+						g.AddVertex(min.X+float32(x[0]+du[0]+dv[0]), min.Y+float32(x[1]+du[1]+dv[1]), min.Z+float32(x[2]+du[2]+dv[2]))
+						// Faces wind counter-clockwise seen from the empty side
+						material := face
+						if face > 0 {
+							g.AddTriangle(ti+0, ti+1, ti+2)
+							g.AddTriangle(ti+1, ti+3, ti+2)
+						} else {
+							material = -face
+							g.AddTriangle(ti+0, ti+2, ti+1)
+							g.AddTriangle(ti+1, ti+2, ti+3)
+						}
+						for k := 0; k < 4; k++ {
+							g.AddMaterial(material)
+						}
 
 						// Clear this part of the mask, so we don't add duplicate faces
 						for l := 0; l < h; l++ {
 							for k := 0; k < w; k++ {
-								mask[n+k+l*ChunkSize] = false
+								mask[n+k+l*size[u]] = 0
 							}
 						}
 
@@ -321,12 +485,4 @@ func (c *Chunk) GreedyGeom() geometry.IGeometry {
 			}
 		}
 	}
-	return g.Build()
-}
-
-func (c *Chunk) Mesh() graphic.IGraphic {
-	mat := material.NewStandard(math32.NewColor("white"))
-	mat.AddTexture(textures["dirt"])
-	mat.SetShader("terrain")
-	return graphic.NewMesh(c.CulledGeom(), mat)
 }
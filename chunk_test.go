@@ -0,0 +1,169 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestChunkTree(t *testing.T) {
+	rand.Seed(1)
+	c := NewChunk()
+	// Partly filled blocks of another material on top
+	for i := 0; i < 200; i++ {
+		c.Set(rand.Intn(ChunkSize), 4+rand.Intn(8), rand.Intn(ChunkSize), Dirt, 0.25+rand.Float32()*0.75)
+	}
+	tree := c.Tree()
+	if b := tree.Bounds(); tree.Size != ChunkSize || b.Min != (math32.Vector3{}) {
+		t.Fatalf("tree covers %v", b)
+	}
+	for x := 0; x < ChunkSize; x++ {
+		for y := 0; y < ChunkSize; y++ {
+			for z := 0; z < ChunkSize; z++ {
+				m, d := c.Get(x, y, z)
+				tm, td := tree.Get(x, y, z)
+				if tm == 0 || td == 0 {
+					tm, td = 0, 0
+				}
+				if m != tm || d != td {
+					t.Fatalf("cell (%d, %d, %d) got %d %.2f, want %d %.2f", x, y, z, tm, td, m, d)
+				}
+			}
+		}
+	}
+	if got, want := crossings(tree), computedCrossings(tree); !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored %d crossings differ from the %d computed", len(got), len(want))
+	}
+	if !reflect.DeepEqual(tree.Chunk(0, 0, 0), c) {
+		t.Fatalf("chunk of the tree differs")
+	}
+
+	// Part of a larger tree, with the cells beyond it empty
+	big := sphereTree(48, 20)
+	part := big.Chunk(20, 8, 0)
+	for x := -1; x <= ChunkSize; x++ {
+		for y := -1; y <= ChunkSize; y++ {
+			for z := -1; z <= ChunkSize; z++ {
+				m, d := part.Get(x, y, z)
+				wm, wd := big.Get(20+x, 8+y, z)
+				if x < 0 || y < 0 || z < 0 || x >= ChunkSize || y >= ChunkSize || z >= ChunkSize || wm == 0 || wd == 0 {
+					wm, wd = 0, 0
+				}
+				if m != wm || d != wd {
+					t.Fatalf("cell (%d, %d, %d) got %d %.2f, want %d %.2f", x, y, z, m, d, wm, wd)
+				}
+			}
+		}
+	}
+}
+
+func TestGreedyMaterials(t *testing.T) {
+	rand.Seed(3)
+	c := NewChunk()
+	c.Set(5, 8, 5, Rock, 1)
+	c.Set(6, 8, 5, Rock, 1)
+	c.Set(20, 0, 20, Empty, 0)
+	solid := 0
+	c.Cells(func([3]int, int, float32) {
+		solid++
+	})
+
+	b := new(GeometryBuilder)
+	greedy(b, c)
+	if v := volume(b); math32.Abs(v-float32(solid)) > 0.01 {
+		t.Fatalf("faces enclose %.2f, want %d blocks", v, solid)
+	}
+	if len(b.materials) != len(b.positions)/3 {
+		t.Fatalf("%d materials for %d vertices", len(b.materials), len(b.positions)/3)
+	}
+	// Just behind every face is a block of its material, and in front of it nothing
+	for i := 0; i < len(b.indices); i += 3 {
+		var p [3]math32.Vector3
+		for j := range p {
+			k := b.indices[i+j] * 3
+			p[j] = math32.Vector3{X: b.positions[k], Y: b.positions[k+1], Z: b.positions[k+2]}
+		}
+		normal := *p[1].Clone().Sub(&p[0]).Cross(p[2].Clone().Sub(&p[0])).Normalize()
+		center := *p[0].Clone().Add(&p[1]).Add(&p[2]).DivideScalar(3)
+		cell := func(side float32) [3]int {
+			q := center.Clone().Add(normal.Clone().MultiplyScalar(side * 0.5))
+			return [3]int{int(math32.Floor(q.X)), int(math32.Floor(q.Y)), int(math32.Floor(q.Z))}
+		}
+		behind, front := cell(-1), cell(1)
+		want := int(b.materials[b.indices[i]])
+		if m, _ := c.Get(behind[0], behind[1], behind[2]); m != want || want == 0 {
+			t.Fatalf("face of material %d at %v is in front of material %d", want, center, m)
+		}
+		if m, _ := c.Get(front[0], front[1], front[2]); m != 0 {
+			t.Fatalf("face at %v faces material %d", center, m)
+		}
+	}
+}
+
+func TestChunkNeighbors(t *testing.T) {
+	rand.Seed(4)
+	grid := ChunkGrid{}
+	for _, p := range [][3]int{{0, 0, 0}, {1, 0, 0}, {0, 0, 1}, {1, 0, 1}, {0, 1, 0}} {
+		grid[p] = NewChunk()
+	}
+	// A column through the chunk above meets the ground of the one below
+	for y := 0; y < ChunkSize; y++ {
+		grid[[3]int{0, 1, 0}].Set(3, y, 3, Rock, 1)
+	}
+	world := func(x, y, z int) int {
+		floor := func(v int) int {
+			if v < 0 {
+				return (v+1)/ChunkSize - 1
+			}
+			return v / ChunkSize
+		}
+		p := [3]int{floor(x), floor(y), floor(z)}
+		if grid[p] == nil {
+			return 0
+		}
+		m, _ := grid[p].Get(x-p[0]*ChunkSize, y-p[1]*ChunkSize, z-p[2]*ChunkSize)
+		return m
+	}
+	// Count the faces between blocks and empty space, and the blocks with such a face, over the whole world
+	faces, exposed := 0, 0
+	for p, c := range grid {
+		c.Cells(func(q [3]int, _ int, _ float32) {
+			x, y, z := p[0]*ChunkSize+q[0], p[1]*ChunkSize+q[1], p[2]*ChunkSize+q[2]
+			open := 0
+			for _, d := range FaceDirections {
+				if world(x+d[0], y+d[1], z+d[2]) == 0 {
+					open++
+				}
+			}
+			faces += open
+			if open > 0 {
+				exposed++
+			}
+		})
+	}
+
+	var area float32
+	gotExposed := 0
+	for p, c := range grid {
+		v := c.WithNeighbors(grid.Neighbors(p))
+		b := new(GeometryBuilder)
+		greedy(b, v)
+		for i := 0; i < len(b.indices); i += 3 {
+			var q [3]math32.Vector3
+			for j := range q {
+				k := b.indices[i+j] * 3
+				q[j] = math32.Vector3{X: b.positions[k], Y: b.positions[k+1], Z: b.positions[k+2]}
+			}
+			area += q[1].Clone().Sub(&q[0]).Cross(q[2].Clone().Sub(&q[0])).Length() / 2
+		}
+		gotExposed += len(CulledGeom(v).GetGeometry().Indices()) / 36
+	}
+	if math32.Abs(area-float32(faces)) > 0.01 {
+		t.Fatalf("greedy meshes cover %.2f faces, want %d", area, faces)
+	}
+	if gotExposed != exposed {
+		t.Fatalf("culled meshes draw %d blocks, want %d", gotExposed, exposed)
+	}
+}
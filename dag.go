@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/g3n/engine/math32"
+)
+
+// A DAG is a read-only octree in which identical subtrees are stored once
+// Subtrees are identical when their nodes have the same payload and shape, wherever they are,
+// since Hermite data is relative to the node holding it
+type DAG struct {
+	Position math32.Vector3
+	Size     float32
+	Nodes    []DAGNode
+	Root     int32
+}
+
+// A DAGNode refers to its children by index into DAG.Nodes, -1 for a missing child
+type DAGNode struct {
+	Material int
+	Density  float32
+	Children [8]int32
+	Hermite  []Crossing
+}
+
+// dagKey identifies a DAGNode by its contents
+type dagKey struct {
+	material int
+	density  uint32
+	children [8]int32
+	hermite  string
+}
+
+func (d *DAGNode) key() dagKey {
+	k := dagKey{material: d.Material, density: math.Float32bits(d.Density), children: d.Children}
+	if len(d.Hermite) > 0 {
+		b := make([]byte, 0, len(d.Hermite)*32)
+		for _, c := range d.Hermite {
+			for _, v := range []uint32{
+				uint32(c.Cell[0]), uint32(c.Cell[1]), uint32(c.Cell[2]), uint32(c.Axis),
+				math.Float32bits(c.Offset), math.Float32bits(c.Normal.X), math.Float32bits(c.Normal.Y), math.Float32bits(c.Normal.Z),
+			} {
+				b = append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+			}
+		}
+		k.hermite = string(b)
+	}
+	return k
+}
+
+// DAG compresses the tree rooted at n, children before parents, reusing the first copy of every subtree
+func (n *Node) DAG() *DAG {
+	d := &DAG{Position: n.Position, Size: n.Size}
+	index := make(map[dagKey]int32)
+	var add func(n *Node) int32
+	add = func(n *Node) int32 {
+		if n == nil {
+			return -1
+		}
+		node := DAGNode{Material: n.Material, Density: n.Density}
+		for i, child := range n.Children {
+			node.Children[i] = add(child)
+		}
+		if len(n.Hermite) > 0 {
+			node.Hermite = append([]Crossing(nil), n.Hermite...)
+		}
+		k := node.key()
+		if i, ok := index[k]; ok {
+			return i
+		}
+		i := int32(len(d.Nodes))
+		d.Nodes = append(d.Nodes, node)
+		index[k] = i
+		return i
+	}
+	d.Root = add(n)
+	return d
+}
+
+// Tree decompresses the DAG into a tree of its own
+func (d *DAG) Tree() *Node {
+	var tree func(i int32, parent *Node, pos math32.Vector3, size float32) *Node
+	tree = func(i int32, parent *Node, pos math32.Vector3, size float32) *Node {
+		if i < 0 {
+			return nil
+		}
+		dn := &d.Nodes[i]
+		n := NewTree(parent, pos, size)
+		n.Material = dn.Material
+		n.Density = dn.Density
+		if len(dn.Hermite) > 0 {
+			n.Hermite = append([]Crossing(nil), dn.Hermite...)
+		}
+		o := size / 4
+		for c, child := range dn.Children {
+			if child < 0 {
+				continue
+			}
+			n.Children[c] = tree(child, n, math32.Vector3{
+				X: pos.X + float32(2*(c>>2&1)-1)*o,
+				Y: pos.Y + float32(2*(c>>1&1)-1)*o,
+				Z: pos.Z + float32(2*(c&1)-1)*o,
+			}, size/2)
+		}
+		return n
+	}
+	return tree(d.Root, nil, d.Position, d.Size)
+}
+
+// Get returns the material and density of unit cell (x, y, z) as Node.Get does
+func (d *DAG) Get(x, y, z int) (material int, density float32) {
+	size := int(d.Size)
+	if x < 0 || y < 0 || z < 0 || x >= size || y >= size || z >= size || d.Root < 0 {
+		return 0, 0
+	}
+	p := [3]int{x, y, z}
+	node := &d.Nodes[d.Root]
+	for node.Children != [8]int32{-1, -1, -1, -1, -1, -1, -1, -1} {
+		size /= 2
+		i := 0
+		for axis := 0; axis < 3; axis++ {
+			if p[axis] >= size {
+				p[axis] -= size
+				i |= 4 >> axis
+			}
+		}
+		if node.Children[i] < 0 {
+			return 0, 0
+		}
+		node = &d.Nodes[node.Children[i]]
+	}
+	return node.Material, node.Density
+}
+
+// Saved DAGs have their own magic, and a version like saved trees
+const (
+	dagMagic   = "NVTD"
+	dagVersion = 1
+)
+
+type dagHeader struct {
+	Magic    [4]byte
+	Version  uint16
+	Position [3]float32
+	Size     float32
+	Root     int32
+	Nodes    uint32
+}
+
+type dagRecord struct {
+	Material int32
+	Density  float32
+	Children [8]int32
+	Hermite  uint32
+}
+
+// Encode writes the DAG to w
+func (d *DAG) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	h := dagHeader{
+		Version:  dagVersion,
+		Position: [3]float32{d.Position.X, d.Position.Y, d.Position.Z},
+		Size:     d.Size,
+		Root:     d.Root,
+		Nodes:    uint32(len(d.Nodes)),
+	}
+	copy(h.Magic[:], dagMagic)
+	if err := binary.Write(bw, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	for _, n := range d.Nodes {
+		r := dagRecord{Material: int32(n.Material), Density: n.Density, Children: n.Children, Hermite: uint32(len(n.Hermite))}
+		if err := binary.Write(bw, binary.LittleEndian, &r); err != nil {
+			return err
+		}
+		for _, c := range n.Hermite {
+			cr := crossingRecord{
+				Cell:   [3]int32{int32(c.Cell[0]), int32(c.Cell[1]), int32(c.Cell[2])},
+				Axis:   uint8(c.Axis),
+				Offset: c.Offset,
+				Normal: [3]float32{c.Normal.X, c.Normal.Y, c.Normal.Z},
+			}
+			if err := binary.Write(bw, binary.LittleEndian, &cr); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// DecodeDAG reads a DAG written by DAG.Encode from r, stopping at its end as Decode does
+func DecodeDAG(r io.Reader) (*DAG, error) {
+	br := byteReader(r)
+	var h dagHeader
+	if err := binary.Read(br, binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+	if string(h.Magic[:]) != dagMagic {
+		return nil, errors.New("decode dag: not a saved DAG")
+	}
+	if h.Version != dagVersion {
+		return nil, fmt.Errorf("decode dag: unsupported version %d, want %d", h.Version, dagVersion)
+	}
+	if !validTreeSize(h.Size) || !finite(h.Position) || h.Root < -1 || int64(h.Root) >= int64(h.Nodes) {
+		return nil, errors.New("decode dag: invalid header")
+	}
+	d := &DAG{Position: math32.Vector3{X: h.Position[0], Y: h.Position[1], Z: h.Position[2]}, Size: h.Size, Root: h.Root}
+	for i := uint32(0); i < h.Nodes; i++ {
+		var nr dagRecord
+		if err := binary.Read(br, binary.LittleEndian, &nr); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		// Children come before their parents, which also rules out cycles
+		for _, c := range nr.Children {
+			if c < -1 || c >= int32(i) {
+				return nil, fmt.Errorf("decode dag: node %d has invalid child %d", i, c)
+			}
+		}
+		n := DAGNode{Material: int(nr.Material), Density: nr.Density, Children: nr.Children}
+		for j := uint32(0); j < nr.Hermite; j++ {
+			var cr crossingRecord
+			if err := binary.Read(br, binary.LittleEndian, &cr); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			n.Hermite = append(n.Hermite, Crossing{
+				Cell:   [3]int{int(cr.Cell[0]), int(cr.Cell[1]), int(cr.Cell[2])},
+				Axis:   int(cr.Axis),
+				Offset: cr.Offset,
+				Normal: math32.Vector3{X: cr.Normal[0], Y: cr.Normal[1], Z: cr.Normal[2]},
+			})
+		}
+		d.Nodes = append(d.Nodes, n)
+	}
+	return d, nil
+}
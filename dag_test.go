@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestDAG(t *testing.T) {
+	// Flat ground with a sphere on it, without merging so that identical subtrees abound
+	tree := sphereTree(32, 6)
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 8; y++ {
+			for z := 0; z < 32; z++ {
+				n := tree.At(float32(x)+0.5, float32(y)+0.5, float32(z)+0.5)
+				n.Material, n.Density = 2, 1
+			}
+		}
+	}
+	tree.Set(3, 9, 3, 1, 0.75)
+	// Empty cells that still hold a material or a density read back as they are
+	tree.Set(5, 9, 5, 3, 0)
+	tree.Set(7, 9, 7, 0, 0.25)
+	nodes := 0
+	tree.DFS(func(*Node, int) bool {
+		nodes++
+		return true
+	})
+
+	d := tree.DAG()
+	if len(d.Nodes)*10 > nodes {
+		t.Fatalf("DAG has %d nodes for a tree of %d", len(d.Nodes), nodes)
+	}
+	if !reflect.DeepEqual(d.Tree(), tree.Clone()) {
+		t.Fatalf("decompressed tree differs")
+	}
+	for x := -1; x <= 32; x++ {
+		for y := -1; y <= 32; y++ {
+			for z := -1; z <= 32; z++ {
+				m, density := d.Get(x, y, z)
+				wm, wd := tree.Get(x, y, z)
+				if m != wm || density != wd {
+					t.Fatalf("get (%d, %d, %d) got %d %.2f, want %d %.2f", x, y, z, m, density, wm, wd)
+				}
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := d.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte(nil), buf.Bytes()...)
+	decoded, err := DecodeDAG(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, d) {
+		t.Fatalf("decoded DAG differs")
+	}
+	if _, err := DecodeDAG(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Fatalf("decoded truncated DAG without error")
+	}
+	// Point the first node's first child, after the 30 byte header and its material and density, at itself
+	bad := append([]byte(nil), data...)
+	copy(bad[30+8:], []byte{0, 0, 0, 0})
+	if _, err := DecodeDAG(bytes.NewReader(bad)); err == nil {
+		t.Fatalf("decoded DAG with a cycle without error")
+	}
+}
+
+func TestDAGSmall(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 1, Y: 1, Z: 1}, 2)
+	d := tree.DAG()
+	if len(d.Nodes) != 1 || d.Root != 0 {
+		t.Fatalf("empty tree got DAG %+v", d)
+	}
+	for i := 0; i < 8; i++ {
+		n := tree.At(float32(i>>2&1)+0.5, float32(i>>1&1)+0.5, float32(i&1)+0.5)
+		n.Material, n.Density = 1, 1
+	}
+	// Without Hermite data the eight children are one node, the root another
+	if d := tree.DAG(); len(d.Nodes) != 2 || d.Nodes[d.Root].Children != [8]int32{0, 0, 0, 0, 0, 0, 0, 0} {
+		t.Fatalf("full tree got DAG %+v", d)
+	}
+}
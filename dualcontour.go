@@ -0,0 +1,164 @@
+package main
+
+import (
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/math32"
+)
+
+// DualContourMesh places one vertex in every cell of the sample grid that the surface passes
+// through, positioned by minimizing the distance to the tangent planes at the cell's edge
+// crossings, so flat faces, creases and corners are all reproduced
+func (n *Node) DualContourMesh(mat *Material) core.INode {
+	root := core.NewNode()
+	m := graphic.NewMesh(DualContourGeom(n), mat)
+	root.Add(m)
+	return root
+}
+
+// DualContourGeom builds the geometry of DualContourMesh for any volume
+func DualContourGeom(v Volume) geometry.IGeometry {
+	b := new(GeometryBuilder)
+	dualContour(b, v)
+	return b.Build()
+}
+
+// A hermiteVolume stores Hermite data, keyed by the edges' cells relative to the volume's minimum corner
+type hermiteVolume interface {
+	storedCrossings() map[edgeKey]Crossing
+}
+
+func (n *Node) storedCrossings() map[edgeKey]Crossing {
+	stored := make(map[edgeKey]Crossing)
+	n.DFS(func(node *Node, _ int) bool {
+		min := n.cell(node)
+		for _, c := range node.Hermite {
+			stored[edgeKey{cell: [3]int{min[0] + c.Cell[0], min[1] + c.Cell[1], min[2] + c.Cell[2]}, axis: c.Axis}] = c
+		}
+		return true
+	})
+	return stored
+}
+
+func dualContour(b *GeometryBuilder, v Volume) {
+	samples := volumeSamples(v)
+	density := func(p [3]int) float32 {
+		return samples[p]
+	}
+	origin := volumeOrigin(v)
+
+	// Hermite data written with the volume is used where present
+	var stored map[edgeKey]Crossing
+	if h, ok := v.(hermiteVolume); ok {
+		stored = h.storedCrossings()
+	}
+
+	// Collect the Hermite data of every edge with one end inside and the other outside,
+	// computing it from the densities for cells that were set directly
+	edges := make(map[edgeKey]Crossing)
+	crossingEdges(samples, func(e edgeKey) {
+		if c, ok := stored[e]; ok {
+			edges[e] = c
+		} else {
+			edges[e] = crossing(density, e)
+		}
+	})
+
+	// The cell with minimum corner c spans samples c to c+1, its vertex is solved from
+	// the crossings on its twelve edges and clamped to the cell
+	vertices := make(map[[3]int]uint32)
+	vertex := func(c [3]int) uint32 {
+		if i, ok := vertices[c]; ok {
+			return i
+		}
+		var q qef
+		cellEdges(c, func(e edgeKey) {
+			if c, ok := edges[e]; ok {
+				q.add(crossingPosition(origin, e, c), c.Normal)
+			}
+		})
+		p := q.solve()
+		min := samplePosition(origin, c)
+		max := samplePosition(origin, [3]int{c[0] + 1, c[1] + 1, c[2] + 1})
+		if p.X < min.X || p.Y < min.Y || p.Z < min.Z || p.X > max.X || p.Y > max.Y || p.Z > max.Z {
+			p = q.massPoint()
+		}
+		i := b.CurrentTriangleIndex()
+		b.AddVertex(p.X, p.Y, p.Z)
+		vertices[c] = i
+		return i
+	}
+
+	dualQuads(b, edges, func(p [3]int) bool {
+		return density(p) >= isoLevel
+	}, vertex)
+}
+
+// crossingEdges calls fn with every edge between two samples that has one end inside the surface and the other outside
+// Edges are owned by the sample at their negative end
+func crossingEdges(samples map[[3]int]float32, fn func(e edgeKey)) {
+	for p, d := range samples {
+		if d < isoLevel {
+			continue
+		}
+		for axis := 0; axis < 3; axis++ {
+			for _, dir := range []int{-1, 1} {
+				q := p
+				q[axis] += dir
+				if samples[q] >= isoLevel {
+					continue
+				}
+				e := edgeKey{cell: p, axis: axis}
+				if dir < 0 {
+					e.cell = q
+				}
+				fn(e)
+			}
+		}
+	}
+}
+
+// cellEdges calls fn with each of the twelve edges of the cell with minimum corner c
+func cellEdges(c [3]int, fn func(e edgeKey)) {
+	for axis := 0; axis < 3; axis++ {
+		u, v := (axis+1)%3, (axis+2)%3
+		for i := 0; i < 4; i++ {
+			o := c
+			o[u] += i & 1
+			o[v] += i >> 1
+			fn(edgeKey{cell: o, axis: axis})
+		}
+	}
+}
+
+// dualQuads joins the vertices of the four cells sharing each crossing edge into a quad
+// facing away from the edge's inside end
+func dualQuads(b *GeometryBuilder, edges map[edgeKey]Crossing, inside func([3]int) bool, vertex func([3]int) uint32) {
+	for e := range edges {
+		u, v := (e.axis+1)%3, (e.axis+2)%3
+		var quad [4]uint32
+		for i, o := range [4][2]int{{-1, -1}, {0, -1}, {0, 0}, {-1, 0}} {
+			c := e.cell
+			c[u] += o[0]
+			c[v] += o[1]
+			quad[i] = vertex(c)
+		}
+		if !inside(e.cell) {
+			quad[1], quad[3] = quad[3], quad[1]
+		}
+		b.AddTriangle(quad[0], quad[1], quad[2])
+		b.AddTriangle(quad[0], quad[2], quad[3])
+	}
+}
+
+// crossingPosition is the point where the surface cuts edge e
+func crossingPosition(origin math32.Vector3, e edgeKey, c Crossing) math32.Vector3 {
+	p := samplePosition(origin, e.cell)
+	p.SetComponent(e.axis, p.Component(e.axis)+c.Offset)
+	return p
+}
+
+func samplePosition(origin math32.Vector3, p [3]int) math32.Vector3 {
+	return math32.Vector3{X: origin.X + float32(p[0]), Y: origin.Y + float32(p[1]), Z: origin.Z + float32(p[2])}
+}
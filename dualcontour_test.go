@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// checkClosed fails unless every triangle edge is matched by exactly one edge running the other way,
+// i.e. the mesh is watertight and consistently wound
+func checkClosed(t *testing.T, b *GeometryBuilder) {
+	t.Helper()
+	edges := make(map[[2]uint32]int)
+	for i := 0; i < len(b.indices); i += 3 {
+		for j := 0; j < 3; j++ {
+			edges[[2]uint32{b.indices[i+j], b.indices[i+(j+1)%3]}]++
+		}
+	}
+	for e, count := range edges {
+		if count != 1 || edges[[2]uint32{e[1], e[0]}] != 1 {
+			t.Fatalf("edge %v used %d times, reverse used %d times", e, count, edges[[2]uint32{e[1], e[0]}])
+		}
+	}
+}
+
+func TestDualContourBlock(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	for x := float32(2.5); x < 6; x++ {
+		for y := float32(2.5); y < 6; y++ {
+			for z := float32(2.5); z < 6; z++ {
+				node := tree.At(x, y, z)
+				node.Material = 1
+				node.Density = 1
+			}
+		}
+	}
+	b := new(GeometryBuilder)
+	dualContour(b, tree)
+	if got := len(b.indices) / 3; got != 192 {
+		t.Fatalf("got %d triangles, want 192", got)
+	}
+	if got := len(b.positions) / 3; got != 98 {
+		t.Fatalf("got %d vertices, want 98", got)
+	}
+	checkClosed(t, b)
+	// The middle of each face of the block from (2, 2, 2) to (6, 6, 6) is flat
+	for i := 0; i < len(b.positions); i += 3 {
+		p := b.positions[i : i+3]
+		inner := 0
+		for _, c := range p {
+			if c > 3 && c < 5 {
+				inner++
+			}
+		}
+		if inner != 2 {
+			continue
+		}
+		for _, c := range p {
+			if (c < 3 || c > 5) && math32.Abs(c-2) > 1e-4 && math32.Abs(c-6) > 1e-4 {
+				t.Fatalf("vertex %v not on block face", p)
+			}
+		}
+	}
+}
+
+func TestQEFCorner(t *testing.T) {
+	var q qef
+	q.add(math32.Vector3{X: 1, Y: 0.2, Z: 0.7}, math32.Vector3{X: 1})
+	q.add(math32.Vector3{X: 0.3, Y: 2, Z: 0.1}, math32.Vector3{Y: 1})
+	q.add(math32.Vector3{X: 0.6, Y: 0.4, Z: 3}, math32.Vector3{Z: 1})
+	p := q.solve()
+	if !p.AlmostEquals(&math32.Vector3{X: 1, Y: 2, Z: 3}, 1e-4) {
+		t.Fatalf("got %v, want (1, 2, 3)", p)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/g3n/engine/math32"
+)
+
+// Saved trees start with this magic and the version of the format that wrote them
+const (
+	encodingMagic   = "NVTR"
+	encodingVersion = 1
+)
+
+// The header of a saved tree, locating its root
+type treeHeader struct {
+	Magic    [4]byte
+	Version  uint16
+	Position [3]float32
+	Size     float32
+}
+
+// The payload of a saved node, followed by its crossings and then its children in order
+type nodeRecord struct {
+	Material int32
+	Density  float32
+	// Bit i is set if child i exists
+	Children uint8
+	Hermite  uint32
+}
+
+type crossingRecord struct {
+	Cell   [3]int32
+	Axis   uint8
+	Offset float32
+	Normal [3]float32
+}
+
+// Encode writes the tree rooted at n to w
+// Only the root's position and size are saved, those of the children follow from it
+func (n *Node) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	h := treeHeader{
+		Version:  encodingVersion,
+		Position: [3]float32{n.Position.X, n.Position.Y, n.Position.Z},
+		Size:     n.Size,
+	}
+	copy(h.Magic[:], encodingMagic)
+	if err := binary.Write(bw, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	if err := n.encode(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (n *Node) encode(w io.Writer) error {
+	r := nodeRecord{
+		Material: int32(n.Material),
+		Density:  n.Density,
+		Hermite:  uint32(len(n.Hermite)),
+	}
+	for i, child := range n.Children {
+		if child != nil {
+			r.Children |= 1 << i
+		}
+	}
+	if err := binary.Write(w, binary.LittleEndian, &r); err != nil {
+		return err
+	}
+	for _, c := range n.Hermite {
+		cr := crossingRecord{
+			Cell:   [3]int32{int32(c.Cell[0]), int32(c.Cell[1]), int32(c.Cell[2])},
+			Axis:   uint8(c.Axis),
+			Offset: c.Offset,
+			Normal: [3]float32{c.Normal.X, c.Normal.Y, c.Normal.Z},
+		}
+		if err := binary.Write(w, binary.LittleEndian, &cr); err != nil {
+			return err
+		}
+	}
+	for _, child := range n.Children {
+		if child != nil {
+			if err := child.encode(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Decode reads a tree written by Encode from r
+// It reads no further than the end of the tree when r is an io.ByteReader, like a *bufio.Reader,
+// so more can be read from r after it; other readers are buffered and may be read past it
+func Decode(r io.Reader) (*Node, error) {
+	br := byteReader(r)
+	var h treeHeader
+	if err := binary.Read(br, binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+	if string(h.Magic[:]) != encodingMagic {
+		return nil, errors.New("decode: not a saved tree")
+	}
+	if h.Version != encodingVersion {
+		return nil, fmt.Errorf("decode: unsupported version %d, want %d", h.Version, encodingVersion)
+	}
+	if !validTreeSize(h.Size) {
+		return nil, fmt.Errorf("decode: invalid tree size %.2f", h.Size)
+	}
+	if !finite(h.Position) {
+		return nil, fmt.Errorf("decode: invalid tree position %v", h.Position)
+	}
+	n := NewTree(nil, math32.Vector3{X: h.Position[0], Y: h.Position[1], Z: h.Position[2]}, h.Size)
+	if err := n.decode(br); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return n, nil
+}
+
+func (n *Node) decode(r io.Reader) error {
+	var nr nodeRecord
+	if err := binary.Read(r, binary.LittleEndian, &nr); err != nil {
+		return err
+	}
+	n.Material = int(nr.Material)
+	n.Density = nr.Density
+	for i := uint32(0); i < nr.Hermite; i++ {
+		var cr crossingRecord
+		if err := binary.Read(r, binary.LittleEndian, &cr); err != nil {
+			return err
+		}
+		n.Hermite = append(n.Hermite, Crossing{
+			Cell:   [3]int{int(cr.Cell[0]), int(cr.Cell[1]), int(cr.Cell[2])},
+			Axis:   int(cr.Axis),
+			Offset: cr.Offset,
+			Normal: math32.Vector3{X: cr.Normal[0], Y: cr.Normal[1], Z: cr.Normal[2]},
+		})
+	}
+	if nr.Children != 0 && n.Leaf() {
+		return fmt.Errorf("decode: node of size %.2f has children", n.Size)
+	}
+	// Children are placed the same way At places them
+	s := n.Size / 2
+	o := n.Size / 4
+	for i := range n.Children {
+		if nr.Children&(1<<i) == 0 {
+			continue
+		}
+		offset := [3]float32{-o, -o, -o}
+		for axis := 0; axis < 3; axis++ {
+			if i&(4>>axis) != 0 {
+				offset[axis] = o
+			}
+		}
+		child := NewTree(n, math32.Vector3{X: n.Position.X + offset[0], Y: n.Position.Y + offset[1], Z: n.Position.Z + offset[2]}, s)
+		if err := child.decode(r); err != nil {
+			return err
+		}
+		n.Children[i] = child
+	}
+	return nil
+}
+
+// validTreeSize reports whether a decoded root size can hold a tree, which is a power of two
+// between a unit cell and maxTreeSize, so halving it reaches unit nodes
+func validTreeSize(size float32) bool {
+	frac, _ := math.Frexp(float64(size))
+	return size >= 1 && size <= maxTreeSize && frac == 0.5
+}
+
+// finite reports whether a decoded position has no infinite or NaN coordinates
+func finite(p [3]float32) bool {
+	for _, c := range p {
+		if math.IsNaN(float64(c)) || math.IsInf(float64(c), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// byteReader returns r if it can read a byte at a time, so decoding stops where the data does,
+// or else a buffered reader reading from it
+func byteReader(r io.Reader) io.Reader {
+	if _, ok := r.(io.ByteReader); ok {
+		return r
+	}
+	return bufio.NewReader(r)
+}
+
+// unexpectedEOF reports running out of data after a header as an error
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
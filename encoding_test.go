@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	merged := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			for z := 0; z < 4; z++ {
+				merged.Set(x, y, z, 2, 1)
+			}
+		}
+	}
+	merged.Set(6, 6, 6, 3, 0.75)
+	merged.merge()
+
+	for _, test := range []struct {
+		name string
+		tree *Node
+	}{
+		{"empty", NewTree(nil, math32.Vector3{X: -2, Y: 0, Z: 2}, 4)},
+		{"sphere", sphereTree(16, 5)},
+		{"merged", merged},
+	} {
+		buf := new(bytes.Buffer)
+		if err := test.tree.Encode(buf); err != nil {
+			t.Fatalf("%s: encode: %v", test.name, err)
+		}
+		decoded, err := Decode(buf)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(decoded, test.tree.Clone()) {
+			t.Fatalf("%s: decoded tree differs, got\n%s\nwant\n%s", test.name, decoded, test.tree)
+		}
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	trees := []*Node{sphereTree(8, 3), sphereTree(16, 5)}
+	buf := new(bytes.Buffer)
+	for _, tree := range trees {
+		if err := tree.Encode(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := trees[0].DAG().Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	for i, tree := range trees {
+		decoded, err := Decode(buf)
+		if err != nil {
+			t.Fatalf("tree %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(decoded, tree.Clone()) {
+			t.Fatalf("tree %d differs", i)
+		}
+	}
+	if _, err := DecodeDAG(buf); err != nil {
+		t.Fatalf("dag after trees: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("%d bytes left after the DAG", buf.Len())
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := sphereTree(8, 3).Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	badMagic := append([]byte("XXXX"), data[4:]...)
+	badVersion := append([]byte(nil), data...)
+	badVersion[4] = 9
+	for name, b := range map[string][]byte{
+		"bad magic":   badMagic,
+		"bad version": badVersion,
+		"truncated":   data[:len(data)-3],
+		"empty":       nil,
+	} {
+		if _, err := Decode(bytes.NewReader(b)); err == nil {
+			t.Fatalf("%s: decoded without error", name)
+		}
+	}
+
+	// The size follows the 4 byte magic, 2 byte version and 12 byte position
+	for _, size := range []float32{float32(math.Inf(1)), float32(math.NaN()), 6, 0.5, maxTreeSize * 2} {
+		b := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(b[18:], math.Float32bits(size))
+		if _, err := Decode(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), "invalid tree size") {
+			t.Fatalf("size %v: got error %v, want an invalid size", size, err)
+		}
+	}
+	b := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(b[6:], math.Float32bits(float32(math.Inf(-1))))
+	if _, err := Decode(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), "invalid tree position") {
+		t.Fatalf("infinite position: got error %v, want an invalid position", err)
+	}
+}
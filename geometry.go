@@ -1,8 +1,6 @@
 package main
 
 import (
-	"fmt"
-
 	"github.com/g3n/engine/geometry"
 	"github.com/g3n/engine/gls"
 	"github.com/g3n/engine/math32"
@@ -10,6 +8,8 @@ import (
 
 type GeometryBuilder struct {
 	positions math32.ArrayF32
+	normals   math32.ArrayF32
+	materials math32.ArrayF32
 	indices   math32.ArrayU32
 }
 
@@ -23,19 +23,44 @@ func (g *GeometryBuilder) AddVertex(x, y, z float32) {
 	g.positions = append(g.positions, z)
 }
 
+// AddNormal sets the normal of the next vertex without one
+// Unless every vertex is given a normal, normals are calculated from the triangles
+func (g *GeometryBuilder) AddNormal(x, y, z float32) {
+	g.normals = append(g.normals, x)
+	g.normals = append(g.normals, y)
+	g.normals = append(g.normals, z)
+}
+
+// AddMaterial sets the material of the next vertex without one, for the terrain shader to texture it by
+// Unless every vertex is given a material, the geometry has none
+func (g *GeometryBuilder) AddMaterial(material int) {
+	g.materials = append(g.materials, float32(material))
+}
+
 func (g *GeometryBuilder) AddTriangle(i, j, k uint32) {
 	g.indices = append(g.indices, i)
 	g.indices = append(g.indices, j)
 	g.indices = append(g.indices, k)
 }
 
+// AddLine adds a segment between two vertices, for geometries drawn as lines, which need a normal for every vertex
+func (g *GeometryBuilder) AddLine(i, j uint32) {
+	g.indices = append(g.indices, i)
+	g.indices = append(g.indices, j)
+}
+
 func (g *GeometryBuilder) Build() geometry.IGeometry {
-	fmt.Println(len(g.positions), len(g.indices))
-	normals := make([]float32, len(g.positions))
+	normals := g.normals
+	if len(normals) != len(g.positions) {
+		normals = make([]float32, len(g.positions))
+		normals = geometry.CalculateNormals(g.indices, g.positions, normals)
+	}
 	geom := geometry.NewGeometry()
 	geom.AddVBO(gls.NewVBO(g.positions).AddAttrib(gls.VertexPosition))
-	normals = geometry.CalculateNormals(g.indices, g.positions, normals)
 	geom.AddVBO(gls.NewVBO(normals).AddAttrib(gls.VertexNormal))
+	if len(g.materials) > 0 && len(g.materials) == len(g.positions)/3 {
+		geom.AddVBO(gls.NewVBO(g.materials).AddCustomAttrib("VertexMaterial", 1))
+	}
 	geom.SetIndices(g.indices)
 	return geom
 }
@@ -9,9 +9,16 @@ import (
 
 var WireframeMaterial *material.Standard
 
+// WireframeMaterials tell things apart by color, the first is WireframeMaterial
+var WireframeMaterials []*material.Standard
+
 func init() {
-	WireframeMaterial = material.NewStandard(math32.NewColor(("White")))
-	WireframeMaterial.SetWireframe(true)
+	for _, name := range []string{"White", "Red", "Orange", "Yellow", "Lime", "Cyan", "Blue", "Magenta"} {
+		m := material.NewStandard(math32.NewColor(name))
+		m.SetWireframe(true)
+		WireframeMaterials = append(WireframeMaterials, m)
+	}
+	WireframeMaterial = WireframeMaterials[0]
 }
 
 func octaveNoise(noise opensimplex.Noise32, iters int, x, y, z float32, persistence, scale float32) float32 {
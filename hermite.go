@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+// Samples with at least this density are inside the surface
+const isoLevel = 0.5
+
+// An edgeKey names the edge from a unit cell's center to the center of its neighbor along the positive axis
+type edgeKey struct {
+	cell [3]int
+	axis int
+}
+
+// A Crossing is where the surface cuts one of the three positive edges of a unit cell,
+// as a fraction Offset of the edge's length from the cell's center, with the surface Normal there
+type Crossing struct {
+	// Cell owning the edge, relative to the minimum cell of the node storing the crossing
+	Cell   [3]int
+	Axis   int
+	Offset float32
+	Normal math32.Vector3
+}
+
+// updateHermite recomputes the crossings of the edges near unit cell p
+func (n *Node) updateHermite(p [3]int) {
+	n.updateHermiteRange(p, p)
+}
+
+// updateHermiteRange recomputes the crossings of the edges near the unit cells from min to max
+// An edge's normal is estimated from the gradient at both of its ends, so it depends on cells
+// up to one step to the side and two steps behind along its axis
+func (n *Node) updateHermiteRange(min, max [3]int) {
+	size := int(n.Size)
+	for x := min[0] - 2; x <= max[0]+1; x++ {
+		for y := min[1] - 2; y <= max[1]+1; y++ {
+			for z := min[2] - 2; z <= max[2]+1; z++ {
+				q := [3]int{x, y, z}
+				if q[0] < 0 || q[1] < 0 || q[2] < 0 || q[0] >= size || q[1] >= size || q[2] >= size {
+					continue
+				}
+				var crossings []Crossing
+				for axis := 0; axis < 3; axis++ {
+					e := edgeKey{cell: q, axis: axis}
+					if crosses(n.density, e) {
+						crossings = append(crossings, crossing(n.density, e))
+					}
+				}
+				node := n.leafAt(q)
+				if node == nil {
+					if len(crossings) == 0 {
+						continue
+					}
+					o := n.origin()
+					node = n.At(o.X+float32(q[0]), o.Y+float32(q[1]), o.Z+float32(q[2]))
+				}
+				base := n.cell(node)
+				rel := [3]int{q[0] - base[0], q[1] - base[1], q[2] - base[2]}
+				hermite := node.Hermite[:0]
+				for _, c := range node.Hermite {
+					if c.Cell != rel {
+						hermite = append(hermite, c)
+					}
+				}
+				for _, c := range crossings {
+					c.Cell = rel
+					hermite = append(hermite, c)
+				}
+				node.Hermite = hermite
+			}
+		}
+	}
+}
+
+// crosses reports whether one end of edge e is inside the surface and the other is not
+func crosses(density func([3]int) float32, e edgeKey) bool {
+	q := e.cell
+	q[e.axis]++
+	return (density(e.cell) >= isoLevel) != (density(q) >= isoLevel)
+}
+
+// crossing interpolates where the surface cuts edge e and estimates the normal there
+// from the density gradient at each end
+func crossing(density func([3]int) float32, e edgeKey) Crossing {
+	return interpolate(density, e, isoLevel)
+}
+
+// interpolate finds where the density along edge e passes through the given isovalue
+func interpolate(density func([3]int) float32, e edgeKey, isovalue float32) Crossing {
+	p := e.cell
+	q := p
+	q[e.axis]++
+	d0, d1 := density(p), density(q)
+	t := (isovalue - d0) / (d1 - d0)
+	g0, g1 := gradient(density, p), gradient(density, q)
+	normal := g0.Lerp(&g1, t).Negate()
+	if normal.LengthSq() == 0 {
+		normal.SetComponent(e.axis, d0-d1)
+	}
+	normal.Normalize()
+	return Crossing{Axis: e.axis, Offset: t, Normal: *normal}
+}
+
+// gradient of the density at cell p by central differences
+func gradient(density func([3]int) float32, p [3]int) math32.Vector3 {
+	var g math32.Vector3
+	for axis := 0; axis < 3; axis++ {
+		a, b := p, p
+		a[axis]--
+		b[axis]++
+		g.SetComponent(axis, (density(b)-density(a))/2)
+	}
+	return g
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// crossings returns the Hermite data stored in the tree keyed by absolute edge
+func crossings(tree *Node) map[edgeKey]Crossing {
+	m := make(map[edgeKey]Crossing)
+	tree.DFS(func(n *Node, _ int) bool {
+		min := tree.cell(n)
+		for _, c := range n.Hermite {
+			e := edgeKey{cell: [3]int{min[0] + c.Cell[0], min[1] + c.Cell[1], min[2] + c.Cell[2]}, axis: c.Axis}
+			c.Cell = [3]int{}
+			m[e] = c
+		}
+		return true
+	})
+	return m
+}
+
+// computedCrossings returns the Hermite data of every crossing edge in the tree, computed from its densities
+func computedCrossings(tree *Node) map[edgeKey]Crossing {
+	m := make(map[edgeKey]Crossing)
+	size := int(tree.Size)
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			for z := 0; z < size; z++ {
+				for axis := 0; axis < 3; axis++ {
+					e := edgeKey{cell: [3]int{x, y, z}, axis: axis}
+					if crosses(tree.density, e) {
+						m[e] = crossing(tree.density, e)
+					}
+				}
+			}
+		}
+	}
+	return m
+}
+
+func TestHermite(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	for x := 0; x < 2; x++ {
+		for y := 0; y < 2; y++ {
+			for z := 0; z < 2; z++ {
+				tree.Set(x, y, z, 1, 1)
+			}
+		}
+	}
+	tree.Set(4, 4, 4, 1, 0.75)
+	tree.Set(4, 5, 4, 1, 0.75)
+	tree.Set(4, 5, 4, 1, 0)
+
+	got := crossings(tree)
+	want := computedCrossings(tree)
+	// 12 faces of the block plus 6 around the single cell
+	if len(want) != 18 {
+		t.Fatalf("got %d crossing edges, want 18", len(want))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored crossings %v, want %v", got, want)
+	}
+	if c := want[edgeKey{cell: [3]int{4, 4, 4}, axis: 1}]; math32.Abs(c.Offset-1.0/3) > 1e-6 || c.Normal != (math32.Vector3{Y: 1}) {
+		t.Fatalf("got crossing %v above cell, want offset 1/3 and normal +Y", c)
+	}
+
+	if !reflect.DeepEqual(crossings(tree.Clone()), want) {
+		t.Fatal("clone lost crossings")
+	}
+	tree.merge()
+	if node := tree.leafAt([3]int{0, 0, 0}); node.Size != 2 {
+		t.Fatalf("block was not merged, got size %.0f", node.Size)
+	}
+	if !reflect.DeepEqual(crossings(tree), want) {
+		t.Fatal("merge lost crossings")
+	}
+}
+
+func TestHermiteGrow(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 2, Y: 2, Z: 2}, 4)
+	tree.Set(0, 1, 1, 1, 1)
+	tree = tree.Grow(-0.5, 1.5, 1.5)
+	got, want := crossings(tree), computedCrossings(tree)
+	if len(want) != 6 {
+		t.Fatalf("got %d crossing edges, want 6", len(want))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored crossings %v after growing, want %v", got, want)
+	}
+}
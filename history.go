@@ -0,0 +1,182 @@
+package main
+
+import "github.com/g3n/engine/math32"
+
+// A History records edits to a tree so they can be undone and redone
+// Each edit is kept as copies of the smallest subtree it changed, from before and after it, which covers
+// changes to materials and densities as well as the nodes it split, merged and pruned; an edit that may grow
+// the tree keeps copies of the whole tree
+// Edits to the tree made other than through the History must not be mixed with undoing and redoing
+type History struct {
+	// How many edits are kept, the oldest are forgotten first
+	Limit int
+	undo  []change
+	redo  []change
+}
+
+// A change replaces the subtree in the place of before with after, or the other way around to undo it
+type change struct {
+	before, after *Node
+	// The subtrees are whole trees
+	root bool
+}
+
+func NewHistory(limit int) *History {
+	return &History{Limit: limit}
+}
+
+// Edit applies a brush edit to the tree containing root, see Node.Edit, and records it
+func (h *History) Edit(root *Node, shape Shape, mode BrushMode, material int) *Node {
+	root = root.Root()
+	bounds := shape.Bounds()
+	if mode == Union {
+		b := root.Bounds()
+		grown := bounds
+		grown.ExpandByScalar(1 + isoLevel)
+		if !boxContains(b, grown) {
+			return h.record(root, root, func(root *Node) *Node {
+				return root.Edit(shape, mode, material)
+			})
+		}
+	}
+	min, max, ok := root.cellRange(bounds)
+	if !ok {
+		return root
+	}
+	return h.record(root, root.touched(min, max), func(root *Node) *Node {
+		return root.Edit(shape, mode, material)
+	})
+}
+
+// boxContains reports whether inner lies within outer on every axis
+// Box3.ContainsBox can't be used, it compares outer's minimum against inner's maximum
+func boxContains(outer, inner math32.Box3) bool {
+	for axis := 0; axis < 3; axis++ {
+		if inner.Min.Component(axis) < outer.Min.Component(axis) || inner.Max.Component(axis) > outer.Max.Component(axis) {
+			return false
+		}
+	}
+	return true
+}
+
+// Set sets unit cell (x, y, z) as Node.Set does and records it
+func (h *History) Set(root *Node, x, y, z int, material int, density float32) *Node {
+	root = root.Root()
+	set := func(root *Node) *Node {
+		return root.Set(x, y, z, material, density)
+	}
+	size := int(root.Size)
+	if x < 0 || y < 0 || z < 0 || x >= size || y >= size || z >= size {
+		if material == 0 || density == 0 {
+			return root
+		}
+		// Grows the tree
+		return h.record(root, root, set)
+	}
+	p := [3]int{x, y, z}
+	return h.record(root, root.touched(p, p), set)
+}
+
+// touched returns the smallest existing node covering every node an edit of the unit cells from min to max
+// may change, which includes the crossings recomputed around them
+func (n *Node) touched(min, max [3]int) *Node {
+	size := int(n.Size)
+	for axis := 0; axis < 3; axis++ {
+		min[axis] = int(math32.Max(float32(min[axis]-2), 0))
+		max[axis] = int(math32.Min(float32(max[axis]+1), float32(size-1)))
+	}
+	return n.enclosing(min, max)
+}
+
+// record applies fn to the tree rooted at root, which changes nothing outside sub, and pushes the change
+func (h *History) record(root, sub *Node, fn func(root *Node) *Node) *Node {
+	c := change{before: sub.Clone(), root: sub == root}
+	pos, size := sub.Position, sub.Size
+	root = fn(root)
+	if c.root {
+		c.after = root.Clone()
+	} else {
+		c.after = root.find(pos, size).Clone()
+	}
+	h.undo = append(h.undo, c)
+	if h.Limit > 0 && len(h.undo) > h.Limit {
+		h.undo = append(h.undo[:0], h.undo[len(h.undo)-h.Limit:]...)
+	}
+	h.redo = h.redo[:0]
+	return root
+}
+
+// Undo reverts the latest edit not yet undone in the tree containing root and returns its root,
+// and whether there was an edit to undo
+func (h *History) Undo(root *Node) (*Node, bool) {
+	if len(h.undo) == 0 {
+		return root.Root(), false
+	}
+	c := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, c)
+	return root.Root().replace(c.before, c.root), true
+}
+
+// Redo applies the latest undone edit again, unless edits were recorded since
+func (h *History) Redo(root *Node) (*Node, bool) {
+	if len(h.redo) == 0 {
+		return root.Root(), false
+	}
+	c := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, c)
+	return root.Root().replace(c.after, c.root), true
+}
+
+// CanUndo and CanRedo report whether Undo and Redo have an edit to apply
+func (h *History) CanUndo() bool { return len(h.undo) > 0 }
+func (h *History) CanRedo() bool { return len(h.redo) > 0 }
+
+// replace puts a copy of sub in the place of the node with its position and size in the tree rooted at n,
+// or makes it the new root, and returns the root
+func (n *Node) replace(sub *Node, root bool) *Node {
+	if root || sub.Size >= n.Size {
+		return sub.Clone()
+	}
+	parent := n.find(sub.Position, sub.Size*2)
+	parent.Children[parent.childIndex(sub.Position)] = sub.clone(parent)
+	return n
+}
+
+// find returns the node at pos of the given size in the tree rooted at n, creating it and the nodes above it
+// as At does if needed
+func (n *Node) find(pos math32.Vector3, size float32) *Node {
+	node := n
+	for node.Size > size {
+		if node.Children == [8]*Node{} && !node.empty() {
+			node.split()
+		}
+		i := node.childIndex(pos)
+		if node.Children[i] == nil {
+			o := node.Size / 4
+			c := node.Position
+			for axis := 0; axis < 3; axis++ {
+				if i&(4>>axis) != 0 {
+					c.SetComponent(axis, c.Component(axis)+o)
+				} else {
+					c.SetComponent(axis, c.Component(axis)-o)
+				}
+			}
+			node.Children[i] = NewTree(node, c, node.Size/2)
+		}
+		node = node.Children[i]
+	}
+	return node
+}
+
+// childIndex returns the index of the child of n containing pos
+func (n *Node) childIndex(pos math32.Vector3) int {
+	i := 0
+	for axis := 0; axis < 3; axis++ {
+		if pos.Component(axis) >= n.Position.Component(axis) {
+			i |= 4 >> axis
+		}
+	}
+	return i
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestHistory(t *testing.T) {
+	tree := sphereTree(16, 4)
+	tree.Prune()
+	tree.merge()
+	h := NewHistory(0)
+	states := []*Node{tree.Clone()}
+	edit := func(fn func(root *Node) *Node) {
+		tree = fn(tree)
+		states = append(states, tree.Clone())
+	}
+	edit(func(root *Node) *Node {
+		return h.Edit(root, Sphere{Center: math32.Vector3{X: 8, Y: 8, Z: 8}, Radius: 2}, Subtract, 0)
+	})
+	edit(func(root *Node) *Node {
+		return h.Edit(root, Box{Center: math32.Vector3{X: 4, Y: 3.5, Z: 4}, HalfSize: math32.Vector3{X: 2, Y: 1.5, Z: 2}}, Union, 2)
+	})
+	edit(func(root *Node) *Node {
+		return h.Edit(root, Sphere{Center: math32.Vector3{X: 8, Y: 12, Z: 8}, Radius: 3}, Paint, 3)
+	})
+	edit(func(root *Node) *Node {
+		return h.Set(root, 15, 0, 15, 4, 0.75)
+	})
+	// Grows the tree
+	edit(func(root *Node) *Node {
+		return h.Edit(root, Sphere{Center: math32.Vector3{X: 17, Y: 8, Z: 8}, Radius: 2}, Union, 1)
+	})
+	if tree.Size == 16 {
+		t.Fatalf("last edit did not grow the tree")
+	}
+	for i := range states[1:] {
+		if reflect.DeepEqual(states[i], states[i+1]) {
+			t.Fatalf("edit %d changed nothing", i)
+		}
+	}
+
+	for i := len(states) - 2; i >= 0; i-- {
+		var ok bool
+		if tree, ok = h.Undo(tree); !ok {
+			t.Fatalf("undo %d failed", i)
+		}
+		if !reflect.DeepEqual(tree.Clone(), states[i]) {
+			t.Fatalf("undo to state %d got a different tree", i)
+		}
+	}
+	if _, ok := h.Undo(tree); ok {
+		t.Fatalf("undo past the first edit")
+	}
+	for i := 1; i < len(states); i++ {
+		var ok bool
+		if tree, ok = h.Redo(tree); !ok {
+			t.Fatalf("redo %d failed", i)
+		}
+		if !reflect.DeepEqual(tree.Clone(), states[i]) {
+			t.Fatalf("redo to state %d got a different tree", i)
+		}
+	}
+	if _, ok := h.Redo(tree); ok {
+		t.Fatalf("redo past the last edit")
+	}
+
+	// A new edit forgets what was undone
+	tree, _ = h.Undo(tree)
+	tree = h.Set(tree, 1, 1, 1, 1, 1)
+	if h.CanRedo() {
+		t.Fatalf("redo after a new edit")
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	tree := sphereTree(16, 4)
+	h := NewHistory(3)
+	first := tree.Clone()
+	for i := 0; i < 5; i++ {
+		tree = h.Set(tree, i, 0, 0, 1, 1)
+	}
+	undone := 0
+	for h.CanUndo() {
+		tree, _ = h.Undo(tree)
+		undone++
+	}
+	if undone != 3 {
+		t.Fatalf("undid %d edits, want 3", undone)
+	}
+	if m, _ := tree.Get(1, 0, 0); m != 1 {
+		t.Fatalf("undid an edit beyond the limit")
+	}
+	if m, _ := tree.Get(2, 0, 0); m != 0 || reflect.DeepEqual(tree.Clone(), first) {
+		t.Fatalf("edits within the limit not undone")
+	}
+}
+
+func TestHistorySetGrowth(t *testing.T) {
+	tree := sphereTree(8, 2)
+	before := tree.Clone()
+	h := NewHistory(0)
+	if root := h.Set(tree, -1, 0, 0, 0, 0); root != tree || h.CanUndo() {
+		t.Fatalf("setting an empty cell outside the tree changed it")
+	}
+	tree = h.Set(tree, -1, 0, 0, 1, 1)
+	if tree.Size != 16 {
+		t.Fatalf("set outside the tree got a root of size %.0f, want 16", tree.Size)
+	}
+	after := tree.Clone()
+	tree, _ = h.Undo(tree)
+	if !reflect.DeepEqual(tree.Clone(), before) {
+		t.Fatalf("undo left a tree of size %.0f, want the original", tree.Size)
+	}
+	tree, _ = h.Redo(tree)
+	if !reflect.DeepEqual(tree.Clone(), after) {
+		t.Fatalf("redo got a different tree")
+	}
+}
+
+func TestHistoryUndoGrowth(t *testing.T) {
+	for axis := 0; axis < 3; axis++ {
+		tree := NewTree(nil, math32.Vector3{X: 8, Y: 8, Z: 8}, 16)
+		for i := 0; i < 2; i++ {
+			for j := 4; j < 6; j++ {
+				p := [3]int{4, 4, 4}
+				p[axis] = i
+				p[(axis+1)%3] = j
+				tree.Set(p[0], p[1], p[2], 1, 1)
+			}
+		}
+		before := tree.Clone()
+		h := NewHistory(0)
+		// A sphere sticking out of the tree's minimum side along axis
+		center := math32.Vector3{X: 5, Y: 5, Z: 5}
+		center.SetComponent(axis, -1)
+		tree = h.Edit(tree, Sphere{Center: center, Radius: 1.2}, Union, 1)
+		if tree.Size == 16 {
+			t.Fatalf("axis %d: edit did not grow the tree", axis)
+		}
+		tree, _ = h.Undo(tree)
+		if !reflect.DeepEqual(tree.Clone(), before) {
+			t.Fatalf("axis %d: undo left a tree of size %.0f, want the original", axis, tree.Size)
+		}
+	}
+}
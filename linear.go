@@ -0,0 +1,254 @@
+package main
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+// A MortonKey locates a node of a LinearTree by the child indices on the path from the root,
+// three bits per level below a leading 1 bit, so the root is 1 and its children are 8 to 15
+// Child indices use the same order as Node.Children, x in the high bit and z in the low bit,
+// so a key is the Morton code of the node's cell at its depth
+type MortonKey uint64
+
+const rootKey MortonKey = 1
+
+// Keys have room for 21 levels below the root
+const maxLinearDepth = 21
+
+func (k MortonKey) Child(i int) MortonKey {
+	return k<<3 | MortonKey(i)
+}
+
+func (k MortonKey) Parent() MortonKey {
+	return k >> 3
+}
+
+// Index is the position of the node among its parent's children
+func (k MortonKey) Index() int {
+	return int(k & 7)
+}
+
+func (k MortonKey) Depth() int {
+	d := 0
+	for ; k > 1; k >>= 3 {
+		d++
+	}
+	return d
+}
+
+// A LinearNode is the payload of a node of a LinearTree
+type LinearNode struct {
+	Material int
+	Density  float32
+}
+
+// A LinearTree is an octree without pointers, storing only the payload of each node
+// in a map keyed by the node's MortonKey; positions, sizes and links are computed from the keys
+type LinearTree struct {
+	Position math32.Vector3
+	Size     float32
+	Nodes    map[MortonKey]LinearNode
+}
+
+func NewLinearTree(pos math32.Vector3, size float32) *LinearTree {
+	return &LinearTree{Position: pos, Size: size, Nodes: map[MortonKey]LinearNode{rootKey: {}}}
+}
+
+// Bounds returns the center and size of the node with key k
+func (t *LinearTree) Bounds(k MortonKey) (math32.Vector3, float32) {
+	d := k.Depth()
+	pos, size := t.Position, t.Size
+	for level := d - 1; level >= 0; level-- {
+		i := int(k>>(3*uint(level))) & 7
+		size /= 2
+		o := size / 2
+		for axis := 0; axis < 3; axis++ {
+			if i&(4>>axis) != 0 {
+				pos.SetComponent(axis, pos.Component(axis)+o)
+			} else {
+				pos.SetComponent(axis, pos.Component(axis)-o)
+			}
+		}
+	}
+	return pos, size
+}
+
+// At returns the key of the unit node containing (x, y, z), creating it and its ancestors if needed
+// and splitting merged nodes on the way, or 0 if the point is outside the tree or its unit nodes lie
+// deeper than keys reach; the tree never grows
+// A point on the boundary between two children belongs to the one on the positive side
+func (t *LinearTree) At(x, y, z float32) MortonKey {
+	if !contains(x, y, z, t.Position.X, t.Position.Y, t.Position.Z, t.Size) {
+		return 0
+	}
+	depth := 0
+	for size := t.Size; size > 1; size /= 2 {
+		depth++
+	}
+	if depth > maxLinearDepth {
+		return 0
+	}
+	k := rootKey
+	p := [3]float32{x, y, z}
+	pos, size := t.Position, t.Size
+	for size > 1 {
+		if t.Leaf(k) && !t.empty(k) {
+			t.split(k)
+		}
+		size /= 2
+		o := size / 2
+		i := 0
+		for axis := 0; axis < 3; axis++ {
+			c := pos.Component(axis)
+			if p[axis] >= c {
+				i |= 4 >> axis
+				pos.SetComponent(axis, c+o)
+			} else {
+				pos.SetComponent(axis, c-o)
+			}
+		}
+		k = k.Child(i)
+		if _, ok := t.Nodes[k]; !ok {
+			t.Nodes[k] = LinearNode{}
+		}
+	}
+	return k
+}
+
+// split undoes merge, giving the merged node with key k eight children with its payload
+func (t *LinearTree) split(k MortonKey) {
+	for i := 0; i < 8; i++ {
+		t.Nodes[k.Child(i)] = t.Nodes[k]
+	}
+	t.Nodes[k] = LinearNode{}
+}
+
+// Leaf reports whether the node with key k has no children
+func (t *LinearTree) Leaf(k MortonKey) bool {
+	for i := 0; i < 8; i++ {
+		if _, ok := t.Nodes[k.Child(i)]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// DFS visits the nodes in the same order as Node.DFS, descending into a node's children while fn returns true
+func (t *LinearTree) DFS(fn func(MortonKey, LinearNode, int) bool) {
+	t.dfs(rootKey, fn, 0)
+}
+
+func (t *LinearTree) dfs(k MortonKey, fn func(MortonKey, LinearNode, int) bool, depth int) {
+	n, ok := t.Nodes[k]
+	if !ok || !fn(k, n, depth) {
+		return
+	}
+	for i := 0; i < 8; i++ {
+		t.dfs(k.Child(i), fn, depth+1)
+	}
+}
+
+// empty follows Node.empty, missing nodes are trivially empty
+func (t *LinearTree) empty(k MortonKey) bool {
+	n, ok := t.Nodes[k]
+	if !ok {
+		return true
+	}
+	for i := 0; i < 8; i++ {
+		if !t.empty(k.Child(i)) {
+			return false
+		}
+	}
+	return n.Material == 0 || n.Density == 0
+}
+
+// merge collapses nodes whose children are all full and of the same material, like Node.merge
+func (t *LinearTree) merge() {
+	t.mergeAt(rootKey, t.Size)
+}
+
+func (t *LinearTree) mergeAt(k MortonKey, size float32) {
+	if _, ok := t.Nodes[k]; !ok || size <= 1 {
+		return
+	}
+	var density float32
+	var mat *int
+	sameMaterial := false
+	for i := 0; i < 8; i++ {
+		c := k.Child(i)
+		t.mergeAt(c, size/2)
+		if !t.empty(c) {
+			child := t.Nodes[c]
+			density += child.Density
+			if mat == nil {
+				mat = &child.Material
+				sameMaterial = true
+			} else if *mat != child.Material {
+				sameMaterial = false
+			}
+		} else {
+			sameMaterial = false
+		}
+	}
+	if mat != nil && sameMaterial && density == 8 {
+		t.Nodes[k] = LinearNode{Material: *mat, Density: density / 8}
+		for i := 0; i < 8; i++ {
+			t.remove(k.Child(i))
+		}
+	}
+}
+
+// remove deletes the node with key k and everything below it
+func (t *LinearTree) remove(k MortonKey) {
+	if _, ok := t.Nodes[k]; !ok {
+		return
+	}
+	delete(t.Nodes, k)
+	for i := 0; i < 8; i++ {
+		t.remove(k.Child(i))
+	}
+}
+
+func (t *LinearTree) Clone() *LinearTree {
+	clone := &LinearTree{Position: t.Position, Size: t.Size, Nodes: make(map[MortonKey]LinearNode, len(t.Nodes))}
+	for k, n := range t.Nodes {
+		clone.Nodes[k] = n
+	}
+	return clone
+}
+
+// Linear converts the tree rooted at n to a LinearTree, dropping its Hermite data
+func (n *Node) Linear() *LinearTree {
+	t := &LinearTree{Position: n.Position, Size: n.Size, Nodes: make(map[MortonKey]LinearNode)}
+	var walk func(n *Node, k MortonKey)
+	walk = func(n *Node, k MortonKey) {
+		t.Nodes[k] = LinearNode{Material: n.Material, Density: n.Density}
+		for i, child := range n.Children {
+			if child != nil {
+				walk(child, k.Child(i))
+			}
+		}
+	}
+	walk(n, rootKey)
+	return t
+}
+
+// Tree converts the LinearTree to a pointer tree
+func (t *LinearTree) Tree() *Node {
+	return t.tree(nil, rootKey)
+}
+
+func (t *LinearTree) tree(parent *Node, k MortonKey) *Node {
+	ln, ok := t.Nodes[k]
+	if !ok {
+		return nil
+	}
+	pos, size := t.Bounds(k)
+	n := NewTree(parent, pos, size)
+	n.Material = ln.Material
+	n.Density = ln.Density
+	for i := range n.Children {
+		n.Children[i] = t.tree(n, k.Child(i))
+	}
+	return n
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestLinearTree(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 8, Y: 8, Z: 8}, 16)
+	linear := NewLinearTree(tree.Position, tree.Size)
+	set := func(x, y, z float32, material int, density float32) {
+		n := tree.At(x, y, z)
+		n.Material, n.Density = material, density
+		k := linear.At(x, y, z)
+		linear.Nodes[k] = LinearNode{Material: material, Density: density}
+		if pos, size := linear.Bounds(k); pos != n.Position || size != n.Size {
+			t.Fatalf("node at (%.2f, %.2f, %.2f) has bounds %v %.2f, want %v %.2f", x, y, z, pos, size, n.Position, n.Size)
+		}
+	}
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			for z := 0; z < 4; z++ {
+				set(float32(x)+0.5, float32(y)+0.5, float32(z)+0.5, 1, 1)
+			}
+		}
+	}
+	set(9.5, 3.5, 12.5, 2, 0.5)
+	set(15.5, 15.5, 15.5, 1, 1)
+	// On the boundary between children
+	set(8, 8, 8, 3, 1)
+	if k := linear.At(16.5, 0, 0); k != 0 {
+		t.Fatalf("got key %d outside the tree, want 0", k)
+	}
+	// The deepest tree keys reach, and one level deeper
+	deep := NewLinearTree(math32.Vector3{}, 1<<maxLinearDepth)
+	if k := deep.At(0.5, 0.5, 0.5); k.Depth() != maxLinearDepth {
+		t.Fatalf("got key at depth %d, want %d", k.Depth(), maxLinearDepth)
+	}
+	deeper := NewLinearTree(math32.Vector3{}, 1<<(maxLinearDepth+1))
+	if k := deeper.At(0.5, 0.5, 0.5); k != 0 || len(deeper.Nodes) != 1 {
+		t.Fatalf("got key %d and %d nodes in a tree too deep for keys, want 0 and the root", k, len(deeper.Nodes))
+	}
+
+	if !reflect.DeepEqual(linear.Tree(), tree) {
+		t.Fatalf("linear tree differs, got\n%s\nwant\n%s", linear.Tree(), tree)
+	}
+	if !reflect.DeepEqual(tree.Linear(), linear) {
+		t.Fatalf("converted tree differs")
+	}
+
+	var got, want []LinearNode
+	linear.DFS(func(_ MortonKey, n LinearNode, _ int) bool {
+		got = append(got, n)
+		return true
+	})
+	tree.DFS(func(n *Node, _ int) bool {
+		want = append(want, LinearNode{Material: n.Material, Density: n.Density})
+		return true
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DFS order differs, got %v, want %v", got, want)
+	}
+
+	clone := linear.Clone()
+	tree.merge()
+	linear.merge()
+	if !reflect.DeepEqual(linear.Tree(), tree) {
+		t.Fatalf("merged linear tree differs, got\n%s\nwant\n%s", linear.Tree(), tree)
+	}
+	if len(clone.Nodes) <= len(linear.Nodes) {
+		t.Fatalf("merging changed the clone, it has %d nodes, merged tree has %d", len(clone.Nodes), len(linear.Nodes))
+	}
+
+	// Writing into the merged block splits it, keeping the rest of the block
+	set(0.5, 0.5, 0.5, 2, 1)
+	if !reflect.DeepEqual(linear.Tree(), tree) {
+		t.Fatalf("linear tree differs after writing into a merged node, got\n%s\nwant\n%s", linear.Tree(), tree)
+	}
+	if m, _ := linear.Tree().Get(1, 1, 1); m != 1 {
+		t.Fatalf("got material %d next to the write, want 1", m)
+	}
+}
+
+func TestMortonKey(t *testing.T) {
+	k := rootKey.Child(5).Child(2).Child(7)
+	if k.Depth() != 3 || k.Index() != 7 || k.Parent().Index() != 2 || k.Parent().Parent().Parent() != rootKey {
+		t.Fatalf("key %b has wrong depth or path", k)
+	}
+}
+
+func fillTerrain(set func(x, y, z float32), size int) {
+	for x := 0; x < size; x++ {
+		for z := 0; z < size; z++ {
+			h := size/4 + (x*7+z*13)%(size/2)
+			for y := 0; y < h; y++ {
+				set(float32(x)+0.5, float32(y)+0.5, float32(z)+0.5)
+			}
+		}
+	}
+}
+
+func BenchmarkPointerTreeBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tree := NewTree(nil, math32.Vector3{X: 32, Y: 32, Z: 32}, 64)
+		fillTerrain(func(x, y, z float32) {
+			n := tree.At(x, y, z)
+			n.Material, n.Density = 1, 1
+		}, 64)
+	}
+}
+
+func BenchmarkLinearTreeBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tree := NewLinearTree(math32.Vector3{X: 32, Y: 32, Z: 32}, 64)
+		fillTerrain(func(x, y, z float32) {
+			tree.Nodes[tree.At(x, y, z)] = LinearNode{Material: 1, Density: 1}
+		}, 64)
+	}
+}
+
+func BenchmarkPointerTreeAt(b *testing.B) {
+	tree := NewTree(nil, math32.Vector3{X: 32, Y: 32, Z: 32}, 64)
+	fillTerrain(func(x, y, z float32) {
+		n := tree.At(x, y, z)
+		n.Material, n.Density = 1, 1
+	}, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.At(float32(i%64)+0.5, float32(i/64%16)+0.5, float32(i/1024%64)+0.5)
+	}
+}
+
+func BenchmarkLinearTreeAt(b *testing.B) {
+	tree := NewLinearTree(math32.Vector3{X: 32, Y: 32, Z: 32}, 64)
+	fillTerrain(func(x, y, z float32) {
+		tree.Nodes[tree.At(x, y, z)] = LinearNode{Material: 1, Density: 1}
+	}, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.At(float32(i%64)+0.5, float32(i/64%16)+0.5, float32(i/1024%64)+0.5)
+	}
+}
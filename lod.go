@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/math32"
+)
+
+// LOD rings say how coarse the mesh may be at each distance from the viewpoint:
+// cells of size 1 closer than Rings[0], of size 2 closer than Rings[1], doubling with every ring
+type LOD struct {
+	Rings []float32
+}
+
+// Size returns the largest cell size allowed at distance d
+func (l LOD) Size(d float32) int {
+	for i, r := range l.Rings {
+		if d < r {
+			return 1 << uint(i)
+		}
+	}
+	return 1 << uint(len(l.Rings))
+}
+
+// LODGeom builds the geometry of the tree around eye, in the tree's coordinates, with each node meshed as
+// a single cell once it is no larger than lod allows at its distance, and seams stitched as in AdaptiveMesh,
+// which it also follows in returning geometry with holes along with an error
+// It makes no graphics, so it may run off the main thread while the tree it is given is not changed
+func (n *Node) LODGeom(eye math32.Vector3, lod LOD) (geometry.IGeometry, error) {
+	b := new(GeometryBuilder)
+	err := n.adaptive(b, n.lodLeaf(eye, lod))
+	return b.Build(), err
+}
+
+// lodLeaf cuts the tree at the nodes without children and those small enough for their distance from eye
+func (n *Node) lodLeaf(eye math32.Vector3, lod LOD) func(*Node, int) bool {
+	return func(node *Node, size int) bool {
+		if node.Children == [8]*Node{} {
+			return true
+		}
+		b := node.Bounds()
+		return size <= lod.Size(b.DistanceToPoint(&eye))
+	}
+}
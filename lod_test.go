@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestLODSize(t *testing.T) {
+	lod := LOD{Rings: []float32{4, 10, 20}}
+	for _, test := range []struct {
+		d    float32
+		want int
+	}{
+		{0, 1}, {3.9, 1}, {4, 2}, {9, 2}, {10, 4}, {19, 4}, {20, 8}, {1000, 8},
+	} {
+		if got := lod.Size(test.d); got != test.want {
+			t.Fatalf("size at distance %.1f got %d, want %d", test.d, got, test.want)
+		}
+	}
+	if got := (LOD{}).Size(0); got != 1 {
+		t.Fatalf("size without rings got %d, want 1", got)
+	}
+}
+
+func TestLODMesh(t *testing.T) {
+	tree := sphereTree(32, 12)
+	center := math32.Vector3{X: 16, Y: 16, Z: 16}
+	eye := math32.Vector3{X: 0, Y: 16, Z: 16}
+
+	full := new(GeometryBuilder)
+	if err := tree.adaptive(full, tree.lodLeaf(eye, LOD{})); err != nil {
+		t.Fatal(err)
+	}
+	b := new(GeometryBuilder)
+	if err := tree.adaptive(b, tree.lodLeaf(eye, LOD{Rings: []float32{6, 12}})); err != nil {
+		t.Fatal(err)
+	}
+	checkClosed(t, b)
+	if len(b.indices) >= len(full.indices)*3/4 {
+		t.Fatalf("LOD mesh has %d triangles, full resolution %d", len(b.indices)/3, len(full.indices)/3)
+	}
+
+	// Vertices stray from the sphere by at most about the size of the cells around them
+	for i := 0; i < len(b.positions); i += 3 {
+		p := math32.Vector3{X: b.positions[i], Y: b.positions[i+1], Z: b.positions[i+2]}
+		tolerance := float32(0.5)
+		if d := p.DistanceTo(&eye); d > 6+2 {
+			tolerance = 2
+		}
+		if r := p.DistanceTo(&center); math32.Abs(r-12) > tolerance {
+			t.Fatalf("vertex %v at radius %.2f, want 12 within %.1f", p, r, tolerance)
+		}
+	}
+	if v := volume(b); v <= 0 {
+		t.Fatalf("LOD mesh encloses volume %.1f, want positive", v)
+	}
+}
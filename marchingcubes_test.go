@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// sphereTree returns a tree with a ball of the given radius at its center, whose density
+// falls off linearly across the unit-wide shell at the radius
+func sphereTree(size, radius float32) *Node {
+	c := size / 2
+	tree := NewTree(nil, math32.Vector3{X: c, Y: c, Z: c}, size)
+	for x := float32(0.5); x < size; x++ {
+		for y := float32(0.5); y < size; y++ {
+			for z := float32(0.5); z < size; z++ {
+				d := radius - math32.Sqrt((x-c)*(x-c)+(y-c)*(y-c)+(z-c)*(z-c)) + 0.5
+				if d > 0 {
+					node := tree.At(x, y, z)
+					node.Material = 1
+					node.Density = math32.Min(d, 1)
+				}
+			}
+		}
+	}
+	return tree
+}
+
+func TestMarchingCubesSphere(t *testing.T) {
+	tree := sphereTree(16, 5)
+	for _, isovalue := range []float32{0.25, 0.5, 0.75} {
+		b := new(GeometryBuilder)
+		marchingCubes(b, tree, isovalue)
+		if len(b.indices) == 0 {
+			t.Fatalf("no triangles at isovalue %.2f", isovalue)
+		}
+		checkClosed(t, b)
+		if len(b.normals) != len(b.positions) {
+			t.Fatalf("got %d normals for %d vertices", len(b.normals)/3, len(b.positions)/3)
+		}
+		want := 5 + 0.5 - isovalue
+		for i := 0; i < len(b.positions); i += 3 {
+			p := math32.Vector3{X: b.positions[i] - 8, Y: b.positions[i+1] - 8, Z: b.positions[i+2] - 8}
+			if r := p.Length(); math32.Abs(r-want) > 0.3 {
+				t.Fatalf("vertex %v at radius %.2f, want %.2f", p, r, want)
+			}
+			normal := math32.Vector3{X: b.normals[i], Y: b.normals[i+1], Z: b.normals[i+2]}
+			if normal.Dot(p.Normalize()) < 0.8 {
+				t.Fatalf("normal %v at %v does not point out of the sphere", normal, p)
+			}
+		}
+		// Triangles wind counter-clockwise seen from outside
+		for i := 0; i < len(b.indices); i += 3 {
+			var v [3]math32.Vector3
+			for j := range v {
+				k := b.indices[i+j] * 3
+				v[j] = math32.Vector3{X: b.positions[k] - 8, Y: b.positions[k+1] - 8, Z: b.positions[k+2] - 8}
+			}
+			e1, e2 := v[1], v[2]
+			face := e1.Sub(&v[0]).Cross(e2.Sub(&v[0]))
+			if face.Dot(&v[0]) <= 0 {
+				t.Fatalf("triangle %v faces inward", v)
+			}
+		}
+	}
+}
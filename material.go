@@ -15,7 +15,10 @@ type Material struct {
 func NewMaterial() *Material {
 	m := new(Material)
 	m.Standard.Init("terrain", math32.NewColor("white"))
-	m.AddTexture(textures["dirt"])
+	// Texture i is for material i+1, the shader falls back to dirt's for meshes without materials
+	for _, name := range []string{"rock", "dirt", "grass", "water"} {
+		m.AddTexture(textures[name])
+	}
 	m.uni.Init("Mode")
 	return m
 }
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+// A MergePolicy decides whether eight sibling nodes without children can be replaced by their parent,
+// and with what material and density
+// Missing children are passed as nil
+type MergePolicy interface {
+	Merge(children [8]*Node) (material int, density float32, ok bool)
+}
+
+// MergeStats counts what a merge did
+type MergeStats struct {
+	// Nodes looked at
+	Visited int
+	// Nodes that took the place of their children
+	Merged int
+	// Children removed by merging
+	Removed int
+}
+
+func (s *MergeStats) add(o MergeStats) {
+	s.Visited += o.Visited
+	s.Merged += o.Merged
+	s.Removed += o.Removed
+}
+
+// Merge collapses the tree bottom up, replacing the children of each node by the node itself
+// wherever policy allows
+// A node holding exactly what each of its children did takes over their crossings, otherwise the crossings
+// around it no longer match the densities and are computed again once the tree is merged
+func (n *Node) Merge(policy MergePolicy) MergeStats {
+	var lossy [][2][3]int
+	stats := n.mergeAt(policy, [3]int{}, &lossy)
+	if len(lossy) > 0 {
+		root := n.Root()
+		base := root.cell(n)
+		for _, r := range lossy {
+			for axis := 0; axis < 3; axis++ {
+				r[0][axis] += base[axis]
+				r[1][axis] += base[axis]
+			}
+			root.updateHermiteRange(r[0], r[1])
+		}
+	}
+	return stats
+}
+
+// mergeAt merges the subtree of n whose minimum cell is min, relative to the node Merge was called on,
+// adding the cells of lossy merges to lossy
+func (n *Node) mergeAt(policy MergePolicy, min [3]int, lossy *[][2][3]int) MergeStats {
+	var stats MergeStats
+	if n == nil {
+		return stats
+	}
+	stats.Visited++
+	if n.Leaf() {
+		return stats
+	}
+	h := int(n.Size / 2)
+	leaves := true
+	for i, child := range n.Children {
+		stats.add(child.mergeAt(policy, childMin(min, h, i), lossy))
+		if child != nil && child.Children != [8]*Node{} {
+			leaves = false
+		}
+	}
+	if !leaves || n.Children == [8]*Node{} {
+		return stats
+	}
+	material, density, ok := policy.Merge(n.Children)
+	if !ok {
+		return stats
+	}
+	exact := true
+	for _, child := range n.Children {
+		if child == nil || child.Material != material || child.Density != density {
+			exact = false
+		}
+	}
+	n.Material = material
+	n.Density = density
+	n.Hermite = nil
+	for i, child := range n.Children {
+		if child == nil {
+			continue
+		}
+		if exact {
+			for _, c := range child.Hermite {
+				c.Cell[0] += (i >> 2 & 1) * h
+				c.Cell[1] += (i >> 1 & 1) * h
+				c.Cell[2] += (i & 1) * h
+				n.Hermite = append(n.Hermite, c)
+			}
+		}
+		n.Children[i] = nil
+		stats.Removed++
+	}
+	if !exact {
+		*lossy = append(*lossy, [2][3]int{min, {min[0] + 2*h - 1, min[1] + 2*h - 1, min[2] + 2*h - 1}})
+	}
+	stats.Merged++
+	return stats
+}
+
+// merge merges the tree without losing anything
+func (n *Node) merge() MergeStats {
+	return n.Merge(ExactMerge{})
+}
+
+// ExactMerge merges children that are all solid with the same material, so nothing is lost
+type ExactMerge struct{}
+
+func (ExactMerge) Merge(children [8]*Node) (int, float32, bool) {
+	var density float32
+	for _, child := range children {
+		if child.empty() || child.Material != children[0].Material {
+			return 0, 0, false
+		}
+		density += child.Density
+	}
+	if density != 8 {
+		return 0, 0, false
+	}
+	return children[0].Material, 1, true
+}
+
+// DensityTolerance merges children that are all non-empty with the same material,
+// and whose densities differ by at most Tolerance, into their average density
+type DensityTolerance struct {
+	Tolerance float32
+}
+
+func (p DensityTolerance) Merge(children [8]*Node) (int, float32, bool) {
+	var density float32
+	min, max := math32.Inf(1), math32.Inf(-1)
+	for _, child := range children {
+		if child.empty() || child.Material != children[0].Material {
+			return 0, 0, false
+		}
+		density += child.Density
+		if child.Density < min {
+			min = child.Density
+		}
+		if child.Density > max {
+			max = child.Density
+		}
+	}
+	if max-min > p.Tolerance {
+		return 0, 0, false
+	}
+	return children[0].Material, density / 8, true
+}
+
+// ErrorMetric merges children, empty or not, into their average density whenever the root mean square
+// difference between the children's densities and the average is at most MaxError
+// The merged node takes the material with the most density among the children
+type ErrorMetric struct {
+	MaxError float32
+}
+
+func (p ErrorMetric) Merge(children [8]*Node) (int, float32, bool) {
+	var densities [8]float32
+	var mean float32
+	for i, child := range children {
+		if !child.empty() {
+			densities[i] = child.Density
+		}
+		mean += densities[i] / 8
+	}
+	if mean == 0 {
+		return 0, 0, false
+	}
+	var sq float32
+	for _, d := range densities {
+		sq += (d - mean) * (d - mean)
+	}
+	if math32.Sqrt(sq/8) > p.MaxError {
+		return 0, 0, false
+	}
+	return dominant(children, func(n *Node) float32 { return n.Density }), mean, true
+}
+
+// MajorityMaterial merges children that are all inside the surface, whatever their materials,
+// into their average density and the material most of them have
+type MajorityMaterial struct{}
+
+func (MajorityMaterial) Merge(children [8]*Node) (int, float32, bool) {
+	var density float32
+	for _, child := range children {
+		if child.empty() || child.Density < isoLevel {
+			return 0, 0, false
+		}
+		density += child.Density
+	}
+	return dominant(children, func(*Node) float32 { return 1 }), density / 8, true
+}
+
+// dominant returns the material of the non-empty children with the largest total weight,
+// the lowest such material on a tie
+func dominant(children [8]*Node, weight func(*Node) float32) int {
+	totals := make(map[int]float32)
+	for _, child := range children {
+		if !child.empty() {
+			totals[child.Material] += weight(child)
+		}
+	}
+	best, bestTotal := 0, float32(-1)
+	for m, total := range totals {
+		if total > bestTotal || (total == bestTotal && m < best) {
+			best, bestTotal = m, total
+		}
+	}
+	return best
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestMergePolicies(t *testing.T) {
+	type cell struct {
+		material int
+		density  float32
+	}
+	full := cell{1, 1}
+	for _, test := range []struct {
+		name     string
+		policy   MergePolicy
+		cells    [8]*cell
+		merged   bool
+		material int
+		density  float32
+	}{
+		{"exact", ExactMerge{}, [8]*cell{&full, &full, &full, &full, &full, &full, &full, &full}, true, 1, 1},
+		{"exact partial", ExactMerge{}, [8]*cell{&full, &full, &full, &full, &full, &full, &full, {1, 0.9}}, false, 0, 0},
+		{"exact missing", ExactMerge{}, [8]*cell{&full, &full, &full, &full, &full, &full, &full, nil}, false, 0, 0},
+		{"exact mixed", ExactMerge{}, [8]*cell{&full, &full, &full, &full, &full, &full, &full, {2, 1}}, false, 0, 0},
+		{"tolerance", DensityTolerance{0.2}, [8]*cell{&full, &full, &full, &full, {1, 0.8}, {1, 0.8}, {1, 0.8}, {1, 0.8}}, true, 1, 0.9},
+		{"tolerance exceeded", DensityTolerance{0.1}, [8]*cell{&full, &full, &full, &full, {1, 0.8}, {1, 0.8}, {1, 0.8}, {1, 0.8}}, false, 0, 0},
+		{"tolerance mixed", DensityTolerance{0.5}, [8]*cell{&full, &full, &full, &full, &full, &full, &full, {2, 1}}, false, 0, 0},
+		// The missing child is 7/8 from the mean and the others 1/8, for an error of sqrt(7)/8
+		{"error", ErrorMetric{0.34}, [8]*cell{&full, &full, {2, 1}, &full, &full, {2, 1}, {2, 1}, nil}, true, 1, 0.875},
+		{"error exceeded", ErrorMetric{0.33}, [8]*cell{&full, &full, {2, 1}, &full, &full, {2, 1}, {2, 1}, nil}, false, 0, 0},
+		{"error weighted", ErrorMetric{1}, [8]*cell{{3, 0.5}, {3, 0.5}, {3, 0.5}, {2, 1}, nil, nil, nil, nil}, true, 3, 0.3125},
+		{"error empty", ErrorMetric{1}, [8]*cell{{3, 0}, nil, nil, nil, nil, nil, nil, nil}, false, 0, 0},
+		{"majority", MajorityMaterial{}, [8]*cell{{2, 1}, &full, {2, 1}, &full, &full, {2, 0.5}, &full, &full}, true, 1, 0.9375},
+		{"majority tie", MajorityMaterial{}, [8]*cell{{2, 1}, {2, 1}, {2, 1}, {2, 1}, {3, 1}, {3, 1}, {3, 1}, {3, 1}}, true, 2, 1},
+		{"majority outside", MajorityMaterial{}, [8]*cell{&full, &full, &full, &full, &full, &full, &full, {1, 0.4}}, false, 0, 0},
+	} {
+		tree := NewTree(nil, math32.Vector3{X: 1, Y: 1, Z: 1}, 2)
+		for i, c := range test.cells {
+			if c != nil {
+				// Set prunes empty cells, which the policies still see as children here
+				n := tree.At(float32(i>>2&1)+0.5, float32(i>>1&1)+0.5, float32(i&1)+0.5)
+				n.Material, n.Density = c.material, c.density
+			}
+		}
+		stats := tree.Merge(test.policy)
+		if merged := tree.Children == [8]*Node{}; merged != test.merged {
+			t.Fatalf("%s: got merged %v, want %v", test.name, merged, test.merged)
+		}
+		if (stats.Merged == 1) != test.merged {
+			t.Fatalf("%s: got stats %+v", test.name, stats)
+		}
+		if test.merged && (tree.Material != test.material || math32.Abs(tree.Density-test.density) > 1e-6) {
+			t.Fatalf("%s: got material %d density %.4f, want %d %.4f", test.name, tree.Material, tree.Density, test.material, test.density)
+		}
+	}
+}
+
+func TestMergeStats(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 2, Y: 2, Z: 2}, 4)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			for z := 0; z < 4; z++ {
+				tree.Set(x, y, z, 1, 1)
+			}
+		}
+	}
+	// 64 cells, 8 nodes above them and the root
+	want := MergeStats{Visited: 73, Merged: 9, Removed: 72}
+	if stats := tree.Merge(ExactMerge{}); stats != want {
+		t.Fatalf("got stats %+v, want %+v", stats, want)
+	}
+	if stats := tree.Merge(ExactMerge{}); stats != (MergeStats{Visited: 1}) {
+		t.Fatalf("merging again got stats %+v", stats)
+	}
+}
+
+func TestMergeCrossings(t *testing.T) {
+	for _, policy := range []MergePolicy{ExactMerge{}, DensityTolerance{0.3}, ErrorMetric{0.3}, MajorityMaterial{}} {
+		tree := sphereTree(16, 5)
+		tree.updateHermiteRange([3]int{}, [3]int{15, 15, 15})
+		tree.Set(3, 3, 3, 2, 0.75)
+		tree.Set(3, 3, 4, 2, 0.5)
+		tree.Merge(policy)
+		if got, want := crossings(tree), computedCrossings(tree); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%T: %d stored crossings, want %d computed from the merged densities", policy, len(got), len(want))
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/graphic"
+)
+
+// A Mesher builds a mesh of a volume without changing it
+// A mesher that could not mesh all of the volume returns what it did mesh along with an error
+type Mesher interface {
+	Mesh(v Volume, mat *Material) (core.INode, error)
+}
+
+// MesherFunc makes a function a Mesher
+type MesherFunc func(v Volume, mat *Material) (core.INode, error)
+
+func (f MesherFunc) Mesh(v Volume, mat *Material) (core.INode, error) {
+	return f(v, mat)
+}
+
+// GeomMesher makes a function building geometry, like GreedyGeom, a Mesher
+type GeomMesher func(v Volume) geometry.IGeometry
+
+func (f GeomMesher) Mesh(v Volume, mat *Material) (core.INode, error) {
+	root := core.NewNode()
+	root.Add(graphic.NewMesh(f(v), mat))
+	return root, nil
+}
+
+// TreeMesher makes a method meshing a tree, like Node.DualContourMesh, a Mesher of any volume,
+// converting other volumes with VolumeTree
+type TreeMesher func(n *Node, mat *Material) core.INode
+
+func (f TreeMesher) Mesh(v Volume, mat *Material) (core.INode, error) {
+	return f(VolumeTree(v), mat), nil
+}
+
+type namedMesher struct {
+	name   string
+	mesher Mesher
+}
+
+// The registered meshers in the order they were registered
+var meshers []namedMesher
+
+// RegisterMesher adds a mesher under name, or replaces the one registered under it
+func RegisterMesher(name string, m Mesher) {
+	for i := range meshers {
+		if meshers[i].name == name {
+			meshers[i].mesher = m
+			return
+		}
+	}
+	meshers = append(meshers, namedMesher{name, m})
+}
+
+// MesherNames returns the names of the registered meshers in the order they were registered
+func MesherNames() []string {
+	names := make([]string, len(meshers))
+	for i, m := range meshers {
+		names[i] = m.name
+	}
+	return names
+}
+
+// LookupMesher returns the mesher registered under name, or nil
+func LookupMesher(name string) Mesher {
+	for _, m := range meshers {
+		if m.name == name {
+			return m.mesher
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterMesher("naive voxels", TreeMesher((*Node).NaiveVoxelMesh))
+	RegisterMesher("merged voxels", TreeMesher(func(n *Node, mat *Material) core.INode {
+		return n.Clone().MergedVoxelMesh(mat)
+	}))
+	RegisterMesher("dual contouring", GeomMesher(DualContourGeom))
+	RegisterMesher("marching cubes", GeomMesher(func(v Volume) geometry.IGeometry {
+		return MarchingCubesGeom(v, isoLevel)
+	}))
+	RegisterMesher("surface nets", GeomMesher(SurfaceNetsGeom))
+	RegisterMesher("adaptive", MesherFunc(func(v Volume, mat *Material) (core.INode, error) {
+		return VolumeTree(v).AdaptiveMesh(mat)
+	}))
+	RegisterMesher("simple blocks", GeomMesher(SimpleGeom))
+	RegisterMesher("culled blocks", GeomMesher(CulledGeom))
+	RegisterMesher("greedy blocks", GeomMesher(GreedyGeom))
+}
+
+// Triangles counts the triangles of the meshes in n and below it
+func Triangles(n core.INode) int {
+	count := 0
+	if g, ok := n.(graphic.IGraphic); ok {
+		if geom := g.GetGeometry(); geom != nil {
+			count += len(geom.Indices()) / 3
+		}
+	}
+	for _, child := range n.GetNode().Children() {
+		count += Triangles(child)
+	}
+	return count
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/core"
+)
+
+func TestMeshers(t *testing.T) {
+	tree := sphereTree(16, 5)
+	c := tree.Chunk(0, 0, 0)
+	before := tree.Clone()
+	for _, name := range MesherNames() {
+		m := LookupMesher(name)
+		for _, v := range []Volume{tree, c} {
+			n, err := m.Mesh(v, nil)
+			if err != nil {
+				t.Fatalf("%s mesher failed on %T: %v", name, v, err)
+			}
+			if Triangles(n) == 0 {
+				t.Fatalf("%s mesher drew no triangles of %T", name, v)
+			}
+		}
+		if !reflect.DeepEqual(tree.Clone(), before) {
+			t.Fatalf("%s mesher changed the tree", name)
+		}
+	}
+
+	saved := append([]namedMesher(nil), meshers...)
+	defer func() { meshers = saved }()
+	empty := MesherFunc(func(Volume, *Material) (core.INode, error) { return core.NewNode(), nil })
+	RegisterMesher("empty", empty)
+	RegisterMesher("naive voxels", empty)
+	names := MesherNames()
+	if len(names) != len(saved)+1 || names[0] != "naive voxels" || names[len(names)-1] != "empty" {
+		t.Fatalf("registered meshers got %v", names)
+	}
+	if n, _ := LookupMesher("naive voxels").Mesh(tree, nil); Triangles(n) != 0 {
+		t.Fatalf("mesher not replaced")
+	}
+	if LookupMesher("missing") != nil {
+		t.Fatalf("looked up a mesher never registered")
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/g3n/engine/core"
@@ -18,12 +17,18 @@ type Node struct {
 	Children [8]*Node
 	Material int
 	Density  float32
+	// Crossings on the positive edges of the unit cells this node covers
+	Hermite []Crossing
 }
 
 func NewTree(parent *Node, pos math32.Vector3, size float32) *Node {
 	return &Node{Position: pos, Size: size, Parent: parent}
 }
 
+// At returns the unit node containing (x, y, z), creating it and the nodes above it if needed,
+// so it is the path for writing to the tree; Lookup reads without changing it
+// A point outside the tree grows it, see Grow, and the Root of the node returned is then the new root
+// Get and Set address the same unit nodes by integer coordinates from the tree's minimum corner
 func (n *Node) At(x, y, z float32) *Node {
 	if n.Leaf() && n.Contains(x, y, z) {
 		return n
@@ -31,6 +36,15 @@ func (n *Node) At(x, y, z float32) *Node {
 	if n.Parent != nil && !n.Contains(x, y, z) {
 		return n.Parent.At(x, y, z)
 	}
+	if !n.Contains(x, y, z) {
+		if root := n.Grow(x, y, z); root != n {
+			return root.At(x, y, z)
+		}
+		return nil
+	}
+	if n.Children == [8]*Node{} && !n.empty() {
+		n.split()
+	}
 	s := n.Size / 2
 	o := n.Size / 4
 	for i, offset := range [8][3]float32{
@@ -54,6 +68,197 @@ func (n *Node) At(x, y, z float32) *Node {
 	return nil
 }
 
+// Roots stop growing at this size, beyond which float32 positions no longer hold every unit cell
+const maxTreeSize = 1 << 24
+
+// Root returns the root of the tree containing n
+func (n *Node) Root() *Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
+
+// Grow doubles the root of the tree containing n until it contains (x, y, z), each time making
+// the old root the child of a new root on the side away from the point, and returns the new root
+// Nodes keep their positions, but integer coordinates, which count from the root's minimum corner, shift
+// A point the root can't contain within maxTreeSize, or that isn't finite, leaves the tree as it is
+// Crossings on the edges leaving the old root's minimum sides, which were outside the tree, are computed
+func (n *Node) Grow(x, y, z float32) *Node {
+	old := n.Root()
+	root := old
+	pos, size := root.Position, root.Size
+	for !contains(x, y, z, pos.X, pos.Y, pos.Z, size) {
+		if size >= maxTreeSize {
+			return root
+		}
+		pos, _ = growStep(pos, size, x, y, z)
+		size *= 2
+	}
+	for !root.Contains(x, y, z) {
+		pos, i := growStep(root.Position, root.Size, x, y, z)
+		parent := NewTree(nil, pos, root.Size*2)
+		parent.Children[i] = root
+		root.Parent = parent
+		root = parent
+	}
+	if root != old {
+		base := root.cell(old)
+		s := int(old.Size)
+		for axis := 0; axis < 3; axis++ {
+			if base[axis] == 0 {
+				continue
+			}
+			max := [3]int{base[0] + s - 1, base[1] + s - 1, base[2] + s - 1}
+			max[axis] = base[axis]
+			root.updateHermiteRange(base, max)
+		}
+	}
+	return root
+}
+
+// growStep returns the position of the parent a root at pos of the given size grows into towards (x, y, z),
+// and the index of the root among its children
+func growStep(pos math32.Vector3, size, x, y, z float32) (math32.Vector3, int) {
+	p := [3]float32{x, y, z}
+	h := size / 2
+	i := 0
+	for axis := 0; axis < 3; axis++ {
+		c := pos.Component(axis)
+		if p[axis] < c {
+			pos.SetComponent(axis, c-h)
+			i |= 4 >> axis
+		} else {
+			pos.SetComponent(axis, c+h)
+		}
+	}
+	return pos, i
+}
+
+// Lookup returns the deepest existing node containing (x, y, z), or nil if the point is outside the tree
+func (n *Node) Lookup(x, y, z float32) *Node {
+	return n.lookup(x, y, z, 0)
+}
+
+// lookup is Lookup stopping at nodes no larger than size
+func (n *Node) lookup(x, y, z, size float32) *Node {
+	for n.Parent != nil && !n.Contains(x, y, z) {
+		n = n.Parent
+	}
+	if !n.Contains(x, y, z) {
+		return nil
+	}
+	for n.Size > size {
+		i := 0
+		if x >= n.Position.X {
+			i |= 4
+		}
+		if y >= n.Position.Y {
+			i |= 2
+		}
+		if z >= n.Position.Z {
+			i |= 1
+		}
+		if n.Children[i] == nil {
+			break
+		}
+		n = n.Children[i]
+	}
+	return n
+}
+
+// Directions to the neighbors of a node sharing a face, an edge or just a corner with it
+var (
+	FaceDirections = [6][3]int{
+		{-1, 0, 0}, {1, 0, 0}, {0, -1, 0}, {0, 1, 0}, {0, 0, -1}, {0, 0, 1},
+	}
+	EdgeDirections = [12][3]int{
+		{0, -1, -1}, {0, -1, 1}, {0, 1, -1}, {0, 1, 1},
+		{-1, 0, -1}, {-1, 0, 1}, {1, 0, -1}, {1, 0, 1},
+		{-1, -1, 0}, {-1, 1, 0}, {1, -1, 0}, {1, 1, 0},
+	}
+	CornerDirections = [8][3]int{
+		{-1, -1, -1}, {-1, -1, 1}, {-1, 1, -1}, {-1, 1, 1},
+		{1, -1, -1}, {1, -1, 1}, {1, 1, -1}, {1, 1, 1},
+	}
+)
+
+// Neighbor returns the node of the same size as n next to it in direction d, one of the face, edge
+// or corner directions, or the smallest existing node containing that one if it was never created
+// or merged into a larger node, or nil past the edge of the tree
+// It walks up from n only as far as needed and creates nothing
+func (n *Node) Neighbor(d [3]int) *Node {
+	x := n.Position.X + float32(d[0])*n.Size
+	y := n.Position.Y + float32(d[1])*n.Size
+	z := n.Position.Z + float32(d[2])*n.Size
+	return n.lookup(x, y, z, n.Size)
+}
+
+// Prune removes the subtrees below n that hold no material, density or Hermite data
+func (n *Node) Prune() {
+	n.prune()
+}
+
+// prune reports whether n is bare once its bare children are removed
+func (n *Node) prune() bool {
+	bare := true
+	for i, child := range n.Children {
+		if child == nil {
+			continue
+		}
+		if child.prune() {
+			n.Children[i] = nil
+		} else {
+			bare = false
+		}
+	}
+	return bare && len(n.Hermite) == 0 && (n.Material == 0 || n.Density == 0)
+}
+
+// Get returns the material and density of unit cell (x, y, z), counted from the minimum corner of the tree
+// Cells outside the tree or never set are empty
+func (n *Node) Get(x, y, z int) (material int, density float32) {
+	node := n.leafAt([3]int{x, y, z})
+	if node == nil {
+		return 0, 0
+	}
+	return node.Material, node.Density
+}
+
+// Set sets the material and density of unit cell (x, y, z), creating it if needed, recomputes
+// the Hermite data of every edge whose crossing or normal depends on that cell, and prunes what is left bare
+// A non-empty cell outside the tree grows it, as At does, and like Edit, Set returns the root of the tree,
+// which is a new one if it grew; the coordinates count from n's minimum corner as it was before the call
+func (n *Node) Set(x, y, z int, material int, density float32) *Node {
+	size := int(n.Size)
+	if x < 0 || y < 0 || z < 0 || x >= size || y >= size || z >= size {
+		if material == 0 || density == 0 {
+			return n.Root()
+		}
+	}
+	o := n.origin()
+	node := n.At(o.X+float32(x), o.Y+float32(y), o.Z+float32(z))
+	if node == nil {
+		return n.Root()
+	}
+	node.Material = material
+	node.Density = density
+	root := node.Root()
+	c := root.cell(node)
+	root.updateHermite(c)
+	root.touched(c, c).pruneUp()
+	return root
+}
+
+// pruneUp prunes n, then removes n and its ancestors below the root for as long as they are bare
+func (n *Node) pruneUp() {
+	for n.prune() && n.Parent != nil {
+		p := n.Parent
+		p.Children[p.childIndex(n.Position)] = nil
+		n = p
+	}
+}
+
 func (n *Node) Leaf() bool {
 	return n.Size <= 1
 }
@@ -62,11 +267,12 @@ func (n *Node) Contains(x, y, z float32) bool {
 	return contains(x, y, z, n.Position.X, n.Position.Y, n.Position.Z, n.Size)
 }
 
+// A box contains its minimum faces but not its maximum ones, so each point is in exactly one of a node's children
 func contains(x, y, z, x1, y1, z1, s float32) bool {
 	o := s / 2
-	return x1-o <= x && x <= x1+o &&
-		y1-o <= y && y <= y1+o &&
-		z1-o <= z && z <= z1+o
+	return x1-o <= x && x < x1+o &&
+		y1-o <= y && y < y1+o &&
+		z1-o <= z && z < z1+o
 }
 
 func (n *Node) DFS(fn func(*Node, int) bool) {
@@ -98,39 +304,114 @@ func (n *Node) empty() bool {
 	return n.Material == 0 || n.Density == 0
 }
 
-// A node can be merged if all its children have the same material and non-zero density
-// The node has the material of its children and the average of the densities
-func (n *Node) merge() {
-	if n != nil {
-		log.Printf("merging %s", n.string())
+// solid reports whether the node's own material fills it as far as blocky meshes and raycasts see,
+// which is when it is inside the surface rather than in its falloff
+func (n *Node) solid() bool {
+	return n.Material != 0 && n.Density >= isoLevel
+}
+
+// split undoes merge, giving a merged node eight children with its material and density
+// and handing each the crossings of its cells
+func (n *Node) split() {
+	h := int(n.Size / 2)
+	o := n.Size / 4
+	for i := range n.Children {
+		child := NewTree(n, math32.Vector3{
+			X: n.Position.X + float32(2*(i>>2&1)-1)*o,
+			Y: n.Position.Y + float32(2*(i>>1&1)-1)*o,
+			Z: n.Position.Z + float32(2*(i&1)-1)*o,
+		}, n.Size/2)
+		child.Material = n.Material
+		child.Density = n.Density
+		n.Children[i] = child
 	}
-	if n == nil || n.Leaf() {
-		return
+	for _, c := range n.Hermite {
+		i := 0
+		for axis := 0; axis < 3; axis++ {
+			if c.Cell[axis] >= h {
+				c.Cell[axis] -= h
+				i |= 4 >> axis
+			}
+		}
+		n.Children[i].Hermite = append(n.Children[i].Hermite, c)
 	}
-	var density float32
-	var mat *int
-	sameMaterial := false
-	for _, child := range n.Children {
-		child.merge()
-		if !child.empty() {
-			density += child.Density
-			if mat == nil {
-				mat = &child.Material
-				sameMaterial = true
-			} else if *mat != child.Material {
-				sameMaterial = false
+	n.Material = 0
+	n.Density = 0
+	n.Hermite = nil
+}
+
+// Cells calls fn with every non-empty unit cell of the tree by its integer coordinates relative to the tree's
+// minimum corner, making the tree a Volume
+// Merged nodes cover, and so fill in, several cells
+func (n *Node) Cells(fn func(p [3]int, material int, density float32)) {
+	n.DFS(func(c *Node, _ int) bool {
+		if c.Children != [8]*Node{} {
+			return true
+		}
+		if !c.empty() {
+			min := n.cell(c)
+			size := int(c.Size)
+			for x := 0; x < size; x++ {
+				for y := 0; y < size; y++ {
+					for z := 0; z < size; z++ {
+						fn([3]int{min[0] + x, min[1] + y, min[2] + z}, c.Material, c.Density)
+					}
+				}
 			}
-		} else {
-			sameMaterial = false
 		}
+		return false
+	})
+}
+
+// leafAt returns the node without children covering unit cell p, or nil if p is outside
+// the tree or in a part of it that was never created
+func (n *Node) leafAt(p [3]int) *Node {
+	size := int(n.Size)
+	if p[0] < 0 || p[1] < 0 || p[2] < 0 || p[0] >= size || p[1] >= size || p[2] >= size {
+		return nil
 	}
-	if mat != nil && sameMaterial && density == 8 {
-		n.Material = *mat
-		n.Density = density / 8
-		for i := range n.Children {
-			n.Children[i] = nil
+	node := n
+	for node.Children != [8]*Node{} {
+		size /= 2
+		i := 0
+		for axis := 0; axis < 3; axis++ {
+			if p[axis] >= size {
+				p[axis] -= size
+				i |= 4 >> axis
+			}
+		}
+		node = node.Children[i]
+		if node == nil {
+			return nil
 		}
 	}
+	return node
+}
+
+// density returns the density of unit cell p, which is zero outside the tree and in empty cells
+func (n *Node) density(p [3]int) float32 {
+	node := n.leafAt(p)
+	if node.empty() {
+		return 0
+	}
+	return node.Density
+}
+
+// cell returns the integer coordinates of the unit cell at the minimum corner of c
+// relative to the minimum corner of the tree rooted at n
+func (n *Node) cell(c *Node) [3]int {
+	o := n.Size/2 - c.Size/2
+	return [3]int{
+		int(math32.Round(c.Position.X - n.Position.X + o)),
+		int(math32.Round(c.Position.Y - n.Position.Y + o)),
+		int(math32.Round(c.Position.Z - n.Position.Z + o)),
+	}
+}
+
+// origin returns the center of the tree's minimum unit cell, where meshers place sample (0, 0, 0)
+func (n *Node) origin() math32.Vector3 {
+	o := n.Size/2 - 0.5
+	return math32.Vector3{X: n.Position.X - o, Y: n.Position.Y - o, Z: n.Position.Z - o}
 }
 
 func (n *Node) Clone() *Node {
@@ -147,6 +428,7 @@ func (n *Node) clone(parent *Node) *Node {
 		Parent:   parent,
 		Material: n.Material,
 		Density:  n.Density,
+		Hermite:  append([]Crossing(nil), n.Hermite...),
 	}
 	for i, child := range n.Children {
 		clone.Children[i] = child.clone(clone)
@@ -171,38 +453,10 @@ func (n *Node) string() string {
 	return fmt.Sprintf("(%.2f, %.2f, %.2f) %.2f, %d, %.2f", n.Position.X, n.Position.Y, n.Position.Z, n.Size, n.Material, n.Density)
 }
 
-func (n *Node) DualContourMesh(mat *Material) core.INode {
-	b := new(GeometryBuilder)
-	n.merge()
-	n.DFS(func(n *Node, _ int) bool {
-		if n.Material != 0 && n.Density != 0 {
-			s := n.Size
-			h := s / 2
-			neighbor := n.At(n.Position.X, n.Position.Y+s, n.Position.Z)
-			if neighbor == nil || neighbor.Material == 0 || neighbor.Density == 0 {
-				i := b.CurrentTriangleIndex()
-				b.AddVertex(n.Position.X-h, n.Position.Y+s-h, n.Position.Z-h)
-				b.AddVertex(n.Position.X+s-h, n.Position.Y+s-h, n.Position.Z-h)
-				b.AddVertex(n.Position.X-h, n.Position.Y+s-h, n.Position.Z+s-h)
-				b.AddVertex(n.Position.X+s-h, n.Position.Y+s-h, n.Position.Z+s-h)
-				b.AddTriangle(i+0, i+2, i+1)
-				b.AddTriangle(i+1, i+2, i+3)
-			}
-			return false
-		}
-		return true
-	})
-	root := core.NewNode()
-	g := b.Build()
-	m := graphic.NewMesh(g, mat)
-	root.Add(m)
-	return root
-}
-
 func (n *Node) NaiveVoxelMesh(mat *Material) core.INode {
 	root := core.NewNode()
 	n.DFS(func(n *Node, _ int) bool {
-		if n.Material != 0 && n.Density != 0 {
+		if n.solid() {
 			g := geometry.NewCube(n.Size)
 			m := graphic.NewMesh(g, mat)
 			m.SetPositionVec(&n.Position)
@@ -48,3 +48,306 @@ func TestMerge(t *testing.T) {
 		t.Fatalf("tree has wrong number of nodes, got %d, want 2", count)
 	}
 }
+
+func TestLookup(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	leaf := tree.At(1.5, 2.5, 6.5)
+	count := func() int {
+		c := 0
+		tree.DFS(func(*Node, int) bool {
+			c++
+			return true
+		})
+		return c
+	}
+	before := count()
+	for _, test := range []struct {
+		x, y, z float32
+		want    *Node
+	}{
+		{1.5, 2.5, 6.5, leaf},
+		{1.2, 2.9, 6.1, leaf},
+		// Inside the size 2 parent of the leaf, beside it
+		{0.5, 2.5, 6.5, leaf.Parent},
+		// Only the root covers the opposite corner
+		{7.5, 7.5, 0.5, tree},
+		{8.5, 0, 0, nil},
+	} {
+		if got := tree.Lookup(test.x, test.y, test.z); got != test.want {
+			t.Fatalf("lookup at (%.2f, %.2f, %.2f) got %v, want %v", test.x, test.y, test.z, got, test.want)
+		}
+		if got := leaf.Lookup(test.x, test.y, test.z); got != test.want {
+			t.Fatalf("lookup from leaf at (%.2f, %.2f, %.2f) got %v, want %v", test.x, test.y, test.z, got, test.want)
+		}
+	}
+	if after := count(); after != before {
+		t.Fatalf("lookup changed the tree from %d to %d nodes", before, after)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	// Nodes created by reads before Lookup existed
+	for x := float32(0.5); x < 8; x += 2 {
+		tree.At(x, x, x)
+	}
+	solid := tree.At(6.5, 6.5, 6.5)
+	solid.Material, solid.Density = 1, 1
+	painted := tree.At(0.5, 6.5, 0.5)
+	painted.Material = 2
+	crossing := tree.At(0.5, 0.5, 6.5)
+	crossing.Hermite = []Crossing{{Axis: 1, Offset: 0.5, Normal: math32.Vector3{Y: 1}}}
+
+	tree.Prune()
+	var got []*Node
+	tree.DFS(func(n *Node, _ int) bool {
+		if n.Children == [8]*Node{} {
+			got = append(got, n)
+		}
+		return true
+	})
+	if len(got) != 2 || got[0] != crossing || got[1] != solid {
+		t.Fatalf("pruned tree has leaves %v, want only the solid node and the one with crossings", got)
+	}
+
+	// Clearing a cell with Set prunes it and its crossings
+	tree = NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	tree.Set(5, 2, 3, 1, 1)
+	tree.Set(5, 2, 3, 0, 0)
+	if tree.Children != [8]*Node{} || len(tree.Hermite) != 0 {
+		t.Fatalf("clearing the only cell left %v", tree)
+	}
+	tree.Set(5, 2, 3, 1, 1)
+	tree.Set(1, 1, 1, 1, 1)
+	tree.Set(1, 1, 1, 0, 0)
+	if tree.Lookup(1.5, 1.5, 1.5) != tree || tree.Lookup(5.5, 2.5, 3.5).Material != 1 {
+		t.Fatalf("clearing a cell did not prune it, or pruned its neighbor")
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	for _, size := range []int{1, 2, 4, 8, 16} {
+		// Off-center so coordinates relative to the minimum corner differ from absolute ones
+		tree := NewTree(nil, math32.Vector3{X: -3, Y: 5, Z: 0.5}, float32(size))
+		id := func(x, y, z int) int {
+			return (x*size+y)*size + z + 1
+		}
+		for x := 0; x < size; x++ {
+			for y := 0; y < size; y++ {
+				for z := 0; z < size; z++ {
+					if root := tree.Set(x, y, z, id(x, y, z), 1); root != tree {
+						t.Fatalf("size %d: set (%d, %d, %d) got root %v", size, x, y, z, root)
+					}
+				}
+			}
+		}
+
+		// Every cell has its own leaf, where At and Lookup find it from any point inside
+		leaves := 0
+		tree.DFS(func(n *Node, _ int) bool {
+			if n.Children == [8]*Node{} {
+				leaves++
+				if n.Size != 1 {
+					t.Fatalf("size %d: leaf %v is not a unit cell", size, n)
+				}
+			}
+			return true
+		})
+		if leaves != size*size*size {
+			t.Fatalf("size %d: got %d leaves, want %d", size, leaves, size*size*size)
+		}
+		min := tree.Bounds().Min
+		for x := 0; x < size; x++ {
+			for y := 0; y < size; y++ {
+				for z := 0; z < size; z++ {
+					if m, d := tree.Get(x, y, z); m != id(x, y, z) || d != 1 {
+						t.Fatalf("size %d: get (%d, %d, %d) got %d %.2f", size, x, y, z, m, d)
+					}
+					// The minimum corner of a cell belongs to it, its maximum corner to the next
+					for _, o := range []float32{0, 0.5, 0.999} {
+						px, py, pz := min.X+float32(x)+o, min.Y+float32(y)+o, min.Z+float32(z)+o
+						if n := tree.Lookup(px, py, pz); n == nil || n.Material != id(x, y, z) {
+							t.Fatalf("size %d: lookup (%.3f, %.3f, %.3f) got %v, want cell (%d, %d, %d)", size, px, py, pz, n, x, y, z)
+						}
+						if n := tree.At(px, py, pz); n == nil || n.Material != id(x, y, z) {
+							t.Fatalf("size %d: at (%.3f, %.3f, %.3f) got %v, want cell (%d, %d, %d)", size, px, py, pz, n, x, y, z)
+						}
+					}
+				}
+			}
+		}
+
+		// Every lattice point inside a node is in exactly one of its children
+		tree.DFS(func(n *Node, _ int) bool {
+			if n.Children == [8]*Node{} {
+				return false
+			}
+			b := n.Bounds()
+			for x := b.Min.X; x <= b.Max.X; x++ {
+				for y := b.Min.Y; y <= b.Max.Y; y++ {
+					for z := b.Min.Z; z <= b.Max.Z; z++ {
+						count := 0
+						for _, c := range n.Children {
+							if c.Contains(x, y, z) {
+								count++
+							}
+						}
+						if want := map[bool]int{true: 1, false: 0}[n.Contains(x, y, z)]; count != want {
+							t.Fatalf("size %d: point (%.2f, %.2f, %.2f) is in %d children of %v, want %d", size, x, y, z, count, n, want)
+						}
+					}
+				}
+			}
+			return true
+		})
+
+		for _, p := range [][3]int{{-1, 0, 0}, {0, size, 0}, {0, 0, size + 3}} {
+			if m, d := tree.Get(p[0], p[1], p[2]); m != 0 || d != 0 {
+				t.Fatalf("size %d: get outside the tree at %v got %d %.2f", size, p, m, d)
+			}
+			// Writing empty cells outside leaves the tree as it is, anything else grows it
+			grown := tree.Clone()
+			if root := grown.Set(p[0], p[1], p[2], 0, 0); root != grown || grown.Parent != nil {
+				t.Fatalf("size %d: setting an empty cell outside the tree at %v grew it", size, p)
+			}
+			root := grown.Set(p[0], p[1], p[2], 7, 0.5)
+			if root == grown || root.Parent != nil || grown.Root() != root {
+				t.Fatalf("size %d: set outside the tree at %v got root %v", size, p, root)
+			}
+			base := root.cell(grown)
+			if m, d := root.Get(base[0]+p[0], base[1]+p[1], base[2]+p[2]); m != 7 || d != 0.5 {
+				t.Fatalf("size %d: set outside the tree at %v then got %d %.2f", size, p, m, d)
+			}
+		}
+	}
+}
+
+func TestGrow(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	tree.Set(2, 3, 4, 1, 1)
+	old := tree.leafAt([3]int{2, 3, 4})
+	root := tree
+
+	for _, p := range [][3]float32{
+		{8.5, 0.5, 0.5},
+		{-0.5, 9.5, 3},
+		{-20, -20, 40},
+	} {
+		n := root.At(p[0], p[1], p[2])
+		if n == nil || n.Size != 1 || !n.Contains(p[0], p[1], p[2]) {
+			t.Fatalf("at (%.2f, %.2f, %.2f) got %v", p[0], p[1], p[2], n)
+		}
+		n.Material, n.Density = 2, 1
+		root = n.Root()
+		if !root.Contains(p[0], p[1], p[2]) || root.Parent != nil {
+			t.Fatalf("root %v does not contain (%.2f, %.2f, %.2f)", root, p[0], p[1], p[2])
+		}
+	}
+	if root.Size != 64 {
+		t.Fatalf("got root of size %.0f, want 64", root.Size)
+	}
+	if tree.Position != (math32.Vector3{X: 4, Y: 4, Z: 4}) || tree.Size != 8 || tree.Root() != root {
+		t.Fatalf("old root moved to %v", tree)
+	}
+	if n := root.Lookup(2.5, 3.5, 4.5); n != old || n.Position != (math32.Vector3{X: 2.5, Y: 3.5, Z: 4.5}) {
+		t.Fatalf("cell set before growing got %v, want %v", n, old)
+	}
+	root.DFS(func(n *Node, _ int) bool {
+		for i, c := range n.Children {
+			if c == nil {
+				continue
+			}
+			if c.Parent != n || c.Size != n.Size/2 {
+				t.Fatalf("child %d of %v is %v", i, n, c)
+			}
+			for axis := 0; axis < 3; axis++ {
+				want := n.Position.Component(axis) - n.Size/4
+				if i&(4>>axis) != 0 {
+					want += n.Size / 2
+				}
+				if c.Position.Component(axis) != want {
+					t.Fatalf("child %d of %v is at %v", i, n, c.Position)
+				}
+			}
+		}
+		return true
+	})
+
+	// Points the tree can never contain leave it as it is
+	size := root.Size
+	for _, p := range []math32.Vector3{
+		{X: math32.Inf(1)},
+		{Y: math32.Inf(-1)},
+		{Z: math32.NaN()},
+		{X: maxTreeSize},
+		{X: -maxTreeSize, Y: maxTreeSize / 2},
+	} {
+		if n := root.At(p.X, p.Y, p.Z); n != nil {
+			t.Fatalf("at %v got %v", p, n)
+		}
+		if got := root.Grow(p.X, p.Y, p.Z); got != root || root.Parent != nil || root.Size != size {
+			t.Fatalf("growing towards %v changed the root to %v", p, got)
+		}
+	}
+}
+
+func TestNeighbor(t *testing.T) {
+	tree := sphereTree(16, 5)
+	tree.Edit(Box{Center: math32.Vector3{X: 4, Y: 4, Z: 4}, HalfSize: math32.Vector3{X: 4, Y: 4, Z: 4}}, Union, 2)
+	var nodes []*Node
+	tree.DFS(func(n *Node, _ int) bool {
+		nodes = append(nodes, n)
+		return true
+	})
+
+	// The smallest node at least as large as n containing the point
+	brute := func(n *Node, x, y, z float32) *Node {
+		var found *Node
+		for _, m := range nodes {
+			if m.Size >= n.Size && m.Contains(x, y, z) && (found == nil || m.Size < found.Size) {
+				found = m
+			}
+		}
+		return found
+	}
+	var dirs [][3]int
+	dirs = append(dirs, FaceDirections[:]...)
+	dirs = append(dirs, EdgeDirections[:]...)
+	dirs = append(dirs, CornerDirections[:]...)
+	seen := make(map[[3]int]bool)
+	for _, d := range dirs {
+		if seen[d] {
+			t.Fatalf("direction %v repeated", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) != 26 {
+		t.Fatalf("got %d directions, want 26", len(seen))
+	}
+
+	larger := 0
+	for _, n := range nodes {
+		for _, d := range dirs {
+			got := n.Neighbor(d)
+			want := brute(n, n.Position.X+float32(d[0])*n.Size, n.Position.Y+float32(d[1])*n.Size, n.Position.Z+float32(d[2])*n.Size)
+			if got != want {
+				t.Fatalf("neighbor %v of %s got %p, want %p", d, n.string(), got, want)
+			}
+			if got != nil && got.Size > n.Size {
+				larger++
+			}
+		}
+	}
+	if larger == 0 {
+		t.Fatalf("no neighbor was larger than its node")
+	}
+
+	leaf := tree.Lookup(8.5, 8.5, 3.5)
+	if allocs := testing.AllocsPerRun(100, func() {
+		for _, d := range CornerDirections {
+			leaf.Neighbor(d)
+		}
+	}); allocs != 0 {
+		t.Fatalf("neighbor queries allocated %.0f times", allocs)
+	}
+}
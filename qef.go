@@ -0,0 +1,81 @@
+package main
+
+import (
+	"github.com/g3n/engine/math32"
+	"github.com/gonum/lapack"
+	"github.com/gonum/lapack/native"
+)
+
+// Singular values smaller than this fraction of the largest are treated as zero,
+// which keeps vertices of flat and creased cells on the surface instead of flying off
+const qefThreshold = 0.1
+
+// A qef accumulates the quadratic error function of a dual contouring cell,
+// the sum of squared distances from a point to the tangent planes at each edge crossing
+type qef struct {
+	ata   [9]float64
+	atb   [3]float64
+	mass  [3]float64
+	count int
+}
+
+// add the tangent plane through p with normal n
+func (q *qef) add(p, n math32.Vector3) {
+	pn := [3]float64{float64(p.X), float64(p.Y), float64(p.Z)}
+	nn := [3]float64{float64(n.X), float64(n.Y), float64(n.Z)}
+	d := nn[0]*pn[0] + nn[1]*pn[1] + nn[2]*pn[2]
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			q.ata[i*3+j] += nn[i] * nn[j]
+		}
+		q.atb[i] += nn[i] * d
+		q.mass[i] += pn[i]
+	}
+	q.count++
+}
+
+// massPoint is the average of the edge crossings
+func (q *qef) massPoint() math32.Vector3 {
+	c := float64(q.count)
+	return math32.Vector3{X: float32(q.mass[0] / c), Y: float32(q.mass[1] / c), Z: float32(q.mass[2] / c)}
+}
+
+// solve minimizes the error using the truncated pseudo-inverse of AᵀA,
+// solving relative to the mass point so that under-determined directions stay near it
+func (q *qef) solve() math32.Vector3 {
+	m := q.massPoint()
+	mass := [3]float64{float64(m.X), float64(m.Y), float64(m.Z)}
+	var rhs [3]float64
+	for i := 0; i < 3; i++ {
+		rhs[i] = q.atb[i]
+		for j := 0; j < 3; j++ {
+			rhs[i] -= q.ata[i*3+j] * mass[j]
+		}
+	}
+
+	a := q.ata
+	var s, u, vt [9]float64
+	work := make([]float64, 1)
+	impl := native.Implementation{}
+	impl.Dgesvd(lapack.SVDAll, lapack.SVDAll, 3, 3, a[:], 3, s[:], u[:], 3, vt[:], 3, work, -1)
+	work = make([]float64, int(work[0]))
+	if !impl.Dgesvd(lapack.SVDAll, lapack.SVDAll, 3, 3, a[:], 3, s[:], u[:], 3, vt[:], 3, work, len(work)) {
+		return m
+	}
+
+	// x = V Σ⁺ Uᵀ rhs
+	var x [3]float64
+	for k := 0; k < 3; k++ {
+		if s[k] <= qefThreshold*s[0] || s[k] == 0 {
+			continue
+		}
+		var ut float64
+		for j := 0; j < 3; j++ {
+			ut += u[j*3+k] * rhs[j]
+		}
+		for i := 0; i < 3; i++ {
+			x[i] += vt[k*3+i] * ut / s[k]
+		}
+	}
+	return math32.Vector3{X: float32(mass[0] + x[0]), Y: float32(mass[1] + x[1]), Z: float32(mass[2] + x[2])}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+// Leaves asks a query for the non-empty nodes without children, at whatever depth they are
+const Leaves = -1
+
+// Bounds returns the box covered by the node
+func (n *Node) Bounds() math32.Box3 {
+	h := math32.Vector3{X: n.Size / 2, Y: n.Size / 2, Z: n.Size / 2}
+	return math32.Box3{Min: *n.Position.Clone().Sub(&h), Max: *n.Position.Clone().Add(&h)}
+}
+
+// QueryBox calls fn with the non-empty nodes intersecting b, see Query
+func (n *Node) QueryBox(b math32.Box3, depth int, fn func(*Node) bool) {
+	n.Query(func(box *math32.Box3) bool {
+		return b.IsIntersectionBox(box)
+	}, depth, fn)
+}
+
+// QuerySphere calls fn with the non-empty nodes within radius of center, see Query
+func (n *Node) QuerySphere(center math32.Vector3, radius float32, depth int, fn func(*Node) bool) {
+	n.Query(func(box *math32.Box3) bool {
+		return box.DistanceToPoint(&center) <= radius
+	}, depth, fn)
+}
+
+// QueryFrustum calls fn with the non-empty nodes that may be visible in f, see Query
+func (n *Node) QueryFrustum(f *math32.Frustum, depth int, fn func(*Node) bool) {
+	n.Query(f.IntersectsBox, depth, fn)
+}
+
+// Query calls fn with the non-empty nodes at the given depth below n whose bounds intersect,
+// and with the non-empty nodes without children above that depth, which cover it there
+// With depth Leaves it calls fn with every intersecting non-empty node without children
+// Subtrees outside the region are skipped, no nodes are created and the query stops when fn returns false
+func (n *Node) Query(intersects func(*math32.Box3) bool, depth int, fn func(*Node) bool) {
+	n.query(intersects, depth, fn)
+}
+
+func (n *Node) query(intersects func(*math32.Box3) bool, depth int, fn func(*Node) bool) bool {
+	if n == nil {
+		return true
+	}
+	b := n.Bounds()
+	if !intersects(&b) {
+		return true
+	}
+	if depth == 0 || n.Children == [8]*Node{} {
+		if n.empty() {
+			return true
+		}
+		return fn(n)
+	}
+	if depth > 0 {
+		depth--
+	}
+	for _, child := range n.Children {
+		if !child.query(intersects, depth, fn) {
+			return false
+		}
+	}
+	return true
+}
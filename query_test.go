@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestQuery(t *testing.T) {
+	tree := sphereTree(16, 5)
+	tree.Edit(Box{Center: math32.Vector3{X: 14, Y: 14, Z: 14}, HalfSize: math32.Vector3{X: 2, Y: 2, Z: 2}}, Union, 2)
+	nodes := func() int {
+		count := 0
+		tree.DFS(func(*Node, int) bool {
+			count++
+			return true
+		})
+		return count
+	}
+	before := nodes()
+
+	// Every non-empty node at the requested depth, or without children above it, that intersects
+	brute := func(intersects func(*math32.Box3) bool, depth int) []*Node {
+		var want []*Node
+		tree.DFS(func(n *Node, d int) bool {
+			if d == depth || n.Children == [8]*Node{} {
+				b := n.Bounds()
+				if !n.empty() && intersects(&b) {
+					want = append(want, n)
+				}
+				return false
+			}
+			return true
+		})
+		return want
+	}
+	collect := func(query func(fn func(*Node) bool)) []*Node {
+		var got []*Node
+		query(func(n *Node) bool {
+			got = append(got, n)
+			return true
+		})
+		return got
+	}
+
+	box := math32.Box3{Min: math32.Vector3{X: 2.5, Y: 7.2, Z: 6.6}, Max: math32.Vector3{X: 5.5, Y: 9, Z: 15}}
+	center, radius := math32.Vector3{X: 12, Y: 12, Z: 9}, float32(3)
+	var m math32.Matrix4
+	m.MakeOrthographic(3, 9, 10, 4, 1, 3)
+	frustum := math32.NewFrustumFromMatrix(&m)
+
+	for _, depth := range []int{Leaves, 0, 1, 2, 3} {
+		for _, test := range []struct {
+			name       string
+			intersects func(*math32.Box3) bool
+			query      func(fn func(*Node) bool)
+		}{
+			{"box", func(b *math32.Box3) bool { return box.IsIntersectionBox(b) }, func(fn func(*Node) bool) {
+				tree.QueryBox(box, depth, fn)
+			}},
+			{"sphere", func(b *math32.Box3) bool { return b.DistanceToPoint(&center) <= radius }, func(fn func(*Node) bool) {
+				tree.QuerySphere(center, radius, depth, fn)
+			}},
+			// Looking down -z from the origin, the frustum covers z from -3 to -1 and misses the tree
+			{"frustum", frustum.IntersectsBox, func(fn func(*Node) bool) {
+				tree.QueryFrustum(frustum, depth, fn)
+			}},
+		} {
+			got := collect(test.query)
+			want := brute(test.intersects, depth)
+			if test.name != "frustum" && len(want) == 0 {
+				t.Fatalf("%s at depth %d: test region contains no nodes", test.name, depth)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s at depth %d: got %d nodes, want %d", test.name, depth, len(got), len(want))
+			}
+		}
+	}
+
+	// A frustum through the whole depth of the tree finds only what is inside its sides
+	m.MakeOrthographic(3, 9, 10, 4, -16, 0)
+	frustum.SetFromMatrix(&m)
+	found := 0
+	tree.QueryFrustum(frustum, Leaves, func(n *Node) bool {
+		if n.Position.X < 3-n.Size/2 || n.Position.X > 9+n.Size/2 || n.Position.Y < 4-n.Size/2 || n.Position.Y > 10+n.Size/2 {
+			t.Fatalf("node at %v is outside the frustum", n.Position)
+		}
+		found++
+		return true
+	})
+	if found == 0 {
+		t.Fatalf("frustum found no nodes")
+	}
+
+	// Stopping early
+	count := 0
+	tree.QueryBox(tree.Bounds(), Leaves, func(*Node) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Fatalf("query called fn %d times after it returned false, want 3", count)
+	}
+
+	if after := nodes(); after != before {
+		t.Fatalf("queries changed the tree from %d to %d nodes", before, after)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/g3n/engine/math32"
+)
+
+// A Hit is where a ray first enters a solid node, one inside the surface
+type Hit struct {
+	Node     *Node
+	Point    math32.Vector3
+	Distance float32
+	// Outward normal of the face the ray entered through, zero if the ray starts inside the node
+	Normal math32.Vector3
+}
+
+// Raycast returns the first solid node without children along the ray from origin in direction dir,
+// no further than maxDist away, visiting children front to back and skipping the ones that were never created
+func (n *Node) Raycast(origin, dir math32.Vector3, maxDist float32) (Hit, bool) {
+	if dir.Length() == 0 {
+		return Hit{}, false
+	}
+	dir.Normalize()
+	var inv math32.Vector3
+	for axis := 0; axis < 3; axis++ {
+		// Infinite where the ray is parallel to an axis, which the slab test handles
+		inv.SetComponent(axis, 1/dir.Component(axis))
+	}
+	return n.raycast(&origin, &dir, &inv, maxDist)
+}
+
+func (n *Node) raycast(origin, dir, inv *math32.Vector3, maxDist float32) (Hit, bool) {
+	tmin, _, axis, ok := n.intersect(origin, inv)
+	if !ok || tmin > maxDist {
+		return Hit{}, false
+	}
+	if n.Children == [8]*Node{} {
+		if !n.solid() {
+			return Hit{}, false
+		}
+		hit := Hit{Node: n, Distance: math32.Max(tmin, 0)}
+		hit.Point = *dir.Clone().MultiplyScalar(hit.Distance).Add(origin)
+		if tmin > 0 {
+			if dir.Component(axis) > 0 {
+				hit.Normal.SetComponent(axis, -1)
+			} else {
+				hit.Normal.SetComponent(axis, 1)
+			}
+		}
+		return hit, true
+	}
+
+	// Children in the order the ray enters them
+	var order [8]int
+	var entry [8]float32
+	count := 0
+	for i, child := range n.Children {
+		if child == nil {
+			continue
+		}
+		t, _, _, ok := child.intersect(origin, inv)
+		if !ok || t > maxDist {
+			continue
+		}
+		j := count
+		for ; j > 0 && entry[j-1] > t; j-- {
+			order[j], entry[j] = order[j-1], entry[j-1]
+		}
+		order[j], entry[j] = i, t
+		count++
+	}
+	for _, i := range order[:count] {
+		if hit, ok := n.Children[i].raycast(origin, dir, inv, maxDist); ok {
+			return hit, true
+		}
+	}
+	return Hit{}, false
+}
+
+// intersect clips the ray to the node's box, returning the distances where it enters and leaves
+// and the axis of the face it enters through
+func (n *Node) intersect(origin, inv *math32.Vector3) (tmin, tmax float32, axis int, ok bool) {
+	tmin, tmax = math32.Inf(-1), math32.Inf(1)
+	h := n.Size / 2
+	for a := 0; a < 3; a++ {
+		c, o, i := n.Position.Component(a), origin.Component(a), inv.Component(a)
+		t0, t1 := (c-h-o)*i, (c+h-o)*i
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		// A ray parallel to the slab and on its boundary gives NaN, and stays in the slab
+		if t0 > tmin {
+			tmin, axis = t0, a
+		}
+		if t1 < tmax {
+			tmax = t1
+		}
+	}
+	return tmin, tmax, axis, tmin <= tmax && tmax >= 0
+}
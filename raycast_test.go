@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestRaycast(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	for _, p := range [][3]int{
+		{2, 2, 2},
+		{5, 2, 2},
+		{2, 6, 6},
+	} {
+		tree.Set(p[0], p[1], p[2], 1, 1)
+	}
+	// An empty cell in front of the first block
+	tree.Set(1, 2, 2, 0, 0)
+
+	for _, test := range []struct {
+		name    string
+		origin  math32.Vector3
+		dir     math32.Vector3
+		maxDist float32
+		hit     bool
+		node    math32.Vector3
+		point   math32.Vector3
+		normal  math32.Vector3
+	}{
+		{"along x", math32.Vector3{X: -1, Y: 2.5, Z: 2.5}, math32.Vector3{X: 1}, 100, true,
+			math32.Vector3{X: 2.5, Y: 2.5, Z: 2.5}, math32.Vector3{X: 2, Y: 2.5, Z: 2.5}, math32.Vector3{X: -1}},
+		{"back along x", math32.Vector3{X: 10, Y: 2.5, Z: 2.5}, math32.Vector3{X: -2}, 100, true,
+			math32.Vector3{X: 5.5, Y: 2.5, Z: 2.5}, math32.Vector3{X: 6, Y: 2.5, Z: 2.5}, math32.Vector3{X: 1}},
+		{"down", math32.Vector3{X: 2.5, Y: 20, Z: 6.5}, math32.Vector3{Y: -1}, 100, true,
+			math32.Vector3{X: 2.5, Y: 6.5, Z: 6.5}, math32.Vector3{X: 2.5, Y: 7, Z: 6.5}, math32.Vector3{Y: 1}},
+		{"diagonal", math32.Vector3{X: 0.5, Y: 0.5, Z: 0.5}, math32.Vector3{X: 1, Y: 1, Z: 1}, 100, true,
+			math32.Vector3{X: 2.5, Y: 2.5, Z: 2.5}, math32.Vector3{X: 2, Y: 2, Z: 2}, math32.Vector3{X: -1}},
+		{"inside", math32.Vector3{X: 5.2, Y: 2.5, Z: 2.5}, math32.Vector3{Z: 1}, 100, true,
+			math32.Vector3{X: 5.5, Y: 2.5, Z: 2.5}, math32.Vector3{X: 5.2, Y: 2.5, Z: 2.5}, math32.Vector3{}},
+		{"too far", math32.Vector3{X: -1, Y: 2.5, Z: 2.5}, math32.Vector3{X: 1}, 2.5, false,
+			math32.Vector3{}, math32.Vector3{}, math32.Vector3{}},
+		{"miss", math32.Vector3{X: -1, Y: 4.5, Z: 2.5}, math32.Vector3{X: 1}, 100, false,
+			math32.Vector3{}, math32.Vector3{}, math32.Vector3{}},
+		{"away", math32.Vector3{X: -1, Y: 2.5, Z: 2.5}, math32.Vector3{X: -1}, 100, false,
+			math32.Vector3{}, math32.Vector3{}, math32.Vector3{}},
+	} {
+		hit, ok := tree.Raycast(test.origin, test.dir, test.maxDist)
+		if ok != test.hit {
+			t.Fatalf("%s: got hit %v, want %v", test.name, ok, test.hit)
+		}
+		if !ok {
+			continue
+		}
+		if hit.Node.Position != test.node {
+			t.Fatalf("%s: hit node at %v, want %v", test.name, hit.Node.Position, test.node)
+		}
+		if hit.Point.DistanceTo(&test.point) > 1e-4 {
+			t.Fatalf("%s: hit point %v, want %v", test.name, hit.Point, test.point)
+		}
+		if hit.Normal != test.normal {
+			t.Fatalf("%s: hit normal %v, want %v", test.name, hit.Normal, test.normal)
+		}
+		if d := hit.Point.DistanceTo(&test.origin); math32.Abs(d-hit.Distance) > 1e-4 {
+			t.Fatalf("%s: hit distance %.2f, want %.2f", test.name, hit.Distance, d)
+		}
+	}
+}
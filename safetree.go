@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A SafeTree shares a tree between goroutines by copy on write
+// Readers take a snapshot, which is never changed afterwards, so they see a consistent tree for as long
+// as they hold it without locking; writers edit a clone of the latest snapshot one at a time and publish it
+// when done, so every edit costs a copy of the tree and batching edits in one Update pays it once
+type SafeTree struct {
+	// Serializes writers
+	mu   sync.Mutex
+	root atomic.Value
+}
+
+func NewSafeTree(root *Node) *SafeTree {
+	t := new(SafeTree)
+	t.root.Store(root)
+	return t
+}
+
+// Snapshot returns the latest published tree
+// It is shared with other readers, so it must not be changed, and meshers that change the tree
+// they are given, like MergedVoxelMesh, need a Clone of it
+func (t *SafeTree) Snapshot() *Node {
+	return t.root.Load().(*Node)
+}
+
+// Update calls fn with a private clone of the latest tree and publishes the root fn returns
+func (t *SafeTree) Update(fn func(root *Node) *Node) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.root.Store(fn(t.Snapshot().Clone()))
+}
+
+// Edit applies a brush edit, see Node.Edit
+func (t *SafeTree) Edit(shape Shape, mode BrushMode, material int) {
+	t.Update(func(root *Node) *Node {
+		return root.Edit(shape, mode, material)
+	})
+}
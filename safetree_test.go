@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestSafeTree(t *testing.T) {
+	tree := NewSafeTree(sphereTree(16, 4))
+	const writes = 50
+	var wg sync.WaitGroup
+
+	// Each update sets two cells far apart to the same material, readers never see them differ
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= writes; i++ {
+			tree.Update(func(root *Node) *Node {
+				root.Set(1, 1, 1, i, 1)
+				root.Set(14, 14, 14, i, 1)
+				return root
+			})
+		}
+	}()
+	// A second writer digs and fills the sphere
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			mode := Union
+			if i%2 == 0 {
+				mode = Subtract
+			}
+			tree.Edit(Sphere{Center: math32.Vector3{X: 8, Y: 8, Z: 8}, Radius: 2}, mode, 1)
+		}
+	}()
+
+	errs := make(chan string, 4)
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := 0
+			for i := 0; i < writes; i++ {
+				snap := tree.Snapshot()
+				a, _ := snap.Get(1, 1, 1)
+				dualContour(new(GeometryBuilder), snap)
+				snap.Lookup(8, 8, 8)
+				b, _ := snap.Get(14, 14, 14)
+				if a != b {
+					errs <- "snapshot changed while reading it, or showed half an update"
+					return
+				}
+				if a < last {
+					errs <- "snapshots went back in time"
+					return
+				}
+				last = a
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if m, _ := tree.Snapshot().Get(14, 14, 14); m != writes {
+		t.Fatalf("got material %d after all updates, want %d", m, writes)
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/g3n/engine/camera"
 	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
 	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/gui"
 	"github.com/g3n/engine/light"
 	"github.com/g3n/engine/math32"
 	"github.com/g3n/engine/renderer"
@@ -25,6 +29,25 @@ type Scene struct {
 	mouseX, mouseY float32
 
 	mat *Material
+
+	// The tree's meshes are moved by offset, half its starting size, which stays put as the tree grows
+	// The highlight outlines the block under the crosshair
+	world     *SafeTree
+	history   *History
+	offset    float32
+	highlight *graphic.Mesh
+	// The index of the mesher shown in meshNames, and a label naming it
+	mesher int
+	label  *gui.Label
+	// Whether the bounds of the tree's nodes are outlined over it, and colored how
+	showBounds     bool
+	boundsColoring BoundsColoring
+	// The level of detail geometry is built in the background around where the camera was, and sent back
+	// to be shown; when the tree changes while it is being built it is built again
+	lodEye      math32.Vector3
+	lodGeoms    chan lodGeom
+	lodBuilding bool
+	lodStale    bool
 }
 
 func NewScene() *Scene {
@@ -60,42 +83,38 @@ func NewScene() *Scene {
 			maxHeight := int(tree.Size)
 			height := int(((noise.Eval3(float32(x), 0, float32(z)) + 1) / 2) * float32(maxHeight))
 			for y := 0; y < height; y++ {
-				node := tree.At(float32(x)-tree.Size/2, float32(y)-tree.Size/2, float32(z)-tree.Size/2)
-				node.Material = 1
-				node.Density = 1
+				tree.Set(x, y, z, 1, 1)
 			}
 		}
 	}
 
-	n1 := tree.Clone().NaiveVoxelMesh(mat)
-	n1.GetNode().SetPosition(tree.Size/2, tree.Size/2, tree.Size/2)
-	n1.SetName("n1")
-	n1.SetVisible(true)
-	scene.Add(n1)
-
-	n2 := tree.Clone().MergedVoxelMesh(mat)
-	n2.GetNode().SetPosition(tree.Size/2, tree.Size/2, tree.Size/2)
-	n2.SetName("n2")
-	n2.SetVisible(false)
-	scene.Add(n2)
+	label := gui.NewLabelWithFont("", font)
+	label.SetPosition(10, 10)
+	scene.Add(label)
 
-	n3 := tree.Clone().DualContourMesh(mat)
-	n3.GetNode().SetPosition(tree.Size/2, tree.Size/2, tree.Size/2)
-	n3.SetName("n3")
-	n3.SetVisible(false)
-	scene.Add(n3)
+	highlight := graphic.NewMesh(geometry.NewCube(1.02), WireframeMaterial)
+	highlight.SetVisible(false)
+	scene.Add(highlight)
 
 	s := &Scene{
-		Node:   scene,
-		cam:    cam,
-		yaw:    3.49,
-		pitch:  -0.81,
-		mouseX: -1,
-		mouseY: -1,
-		mat:    mat,
+		Node:      scene,
+		cam:       cam,
+		yaw:       3.49,
+		pitch:     -0.81,
+		mouseX:    -1,
+		mouseY:    -1,
+		mat:       mat,
+		world:     NewSafeTree(tree),
+		history:   NewHistory(100),
+		lodGeoms:  make(chan lodGeom, 1),
+		offset:    tree.Size / 2,
+		highlight: highlight,
+		label:     label,
 	}
+	s.buildMeshes()
 	a.SubscribeID(window.OnCursor, a, s.OnMouseMove)
 	a.SubscribeID(window.OnKeyDown, a, s.OnKeyDown)
+	a.SubscribeID(window.OnMouseDown, a, s.OnMouseDown)
 	window.Get().(*window.GlfwWindow).SetInputMode(glfw.InputMode(glfw.CursorMode), glfw.CursorDisabled)
 
 	return s
@@ -121,32 +140,206 @@ func (s *Scene) OnKeyDown(evname string, ev interface{}) {
 		if s.mat.Mode >= 3 {
 			s.mat.Mode = 0
 		}
-	} else if e.Key == window.Key1 {
-		s.FindPath("/n1").SetVisible(true)
-		s.FindPath("/n2").SetVisible(false)
-		s.FindPath("/n3").SetVisible(false)
-	} else if e.Key == window.Key2 {
-		s.FindPath("/n1").SetVisible(false)
-		s.FindPath("/n2").SetVisible(true)
-		s.FindPath("/n3").SetVisible(false)
-	} else if e.Key == window.Key3 {
-		s.FindPath("/n1").SetVisible(false)
-		s.FindPath("/n2").SetVisible(false)
-		s.FindPath("/n3").SetVisible(true)
+	} else if e.Key == window.KeyZ && e.Mods&window.ModControl != 0 {
+		s.undo(e.Mods&window.ModShift != 0)
+	} else if e.Key == window.KeyY && e.Mods&window.ModControl != 0 {
+		s.undo(true)
+	} else if e.Key == window.KeyB {
+		// Cycles through bounds colored by depth, by material and none
+		if !s.showBounds {
+			s.showBounds, s.boundsColoring = true, BoundsByDepth
+		} else if s.boundsColoring == BoundsByDepth {
+			s.boundsColoring = BoundsByMaterial
+		} else {
+			s.showBounds = false
+		}
+		s.buildBounds()
+	} else if e.Key == window.KeyTab {
+		step := 1
+		if e.Mods&window.ModShift != 0 {
+			step = len(meshNames()) - 1
+		}
+		s.showMesher((s.mesher + step) % len(meshNames()))
+	} else if e.Key >= window.Key1 && e.Key <= window.Key9 {
+		s.showMesher(int(e.Key - window.Key1))
 	}
 }
 
-func (s *Scene) Update(renderer *renderer.Renderer, deltaTime time.Duration) {
-	width, height := a.GetFramebufferSize()
-	a.Gls().Viewport(0, 0, int32(width), int32(height))
-	s.cam.SetAspect(float32(width) / float32(height))
+// The level of detail mesh follows the camera, so it is shown after the registered meshers rather than registered
+const lodMesher = "level of detail"
+
+// meshNames returns the names of the meshers the scene shows in turn
+func meshNames() []string {
+	return append(MesherNames(), lodMesher)
+}
+
+// showMesher switches to mesher i of meshNames, if there is one
+func (s *Scene) showMesher(i int) {
+	if i < 0 || i >= len(meshNames()) || i == s.mesher {
+		return
+	}
+	s.mesher = i
+	s.buildMeshes()
+}
+
+// Cell sizes double 16, 32 and 64 units from the camera
+var lod = LOD{Rings: []float32{16, 32, 64}}
+
+// buildMeshes replaces the mesh of the tree with one built from its current state by the mesher shown
+func (s *Scene) buildMeshes() {
+	name := meshNames()[s.mesher]
+	if name == lodMesher {
+		s.buildLOD()
+	} else {
+		s.showMesh(LookupMesher(name).Mesh(s.world.Snapshot(), s.mat))
+	}
+	s.buildBounds()
+}
+
+// showMesh replaces the mesh of the tree with n, naming its mesher and counting its triangles on screen,
+// along with the error its mesher returned, if any
+func (s *Scene) showMesh(n core.INode, err error) {
+	s.replaceMesh("/mesh", n)
+	text := fmt.Sprintf("%s: %d triangles (Tab for the next mesher)", meshNames()[s.mesher], Triangles(n))
+	if err != nil {
+		text += "\n" + err.Error()
+	}
+	s.label.SetText(text)
+}
+
+// buildBounds replaces the outlines of the tree's nodes, or removes them when they are not shown
+func (s *Scene) buildBounds() {
+	if !s.showBounds {
+		s.removeMesh("/bounds")
+		return
+	}
+	s.replaceMesh("/bounds", s.world.Snapshot().BoundsMesh(s.boundsColoring))
+}
+
+// buildLOD starts building a level of detail mesh around the camera's current position
+func (s *Scene) buildLOD() {
+	if s.lodBuilding {
+		s.lodStale = true
+		return
+	}
+	s.lodBuilding = true
+	s.lodStale = false
+	s.lodEye = s.cam.Position()
+	eye, _, _ := s.eye()
+	tree := s.world.Snapshot()
+	go func() {
+		g, err := tree.LODGeom(eye, lod)
+		s.lodGeoms <- lodGeom{g, err}
+	}()
+}
+
+// Level of detail geometry built in the background and the error building it, if any
+// Its mesh is made on the main thread, as making one takes a reference to the shared material
+type lodGeom struct {
+	geom geometry.IGeometry
+	err  error
+}
+
+func (s *Scene) replaceMesh(name string, n core.INode) {
+	s.removeMesh(name)
+	n.GetNode().SetPosition(s.offset, s.offset, s.offset)
+	n.SetName(name[1:])
+	s.Add(n)
+}
+
+// removeMesh removes the mesh at path name, if any, and frees the geometry of the graphics in it
+func (s *Scene) removeMesh(name string) {
+	old := s.FindPath(name)
+	if old == nil {
+		return
+	}
+	s.Remove(old)
+	// Graphics release their materials when disposed without holding a reference to them, so one is taken
+	// for each first, which keeps shared materials like s.mat alive
+	holdMaterials(old)
+	old.GetNode().DisposeChildren(true)
+	old.Dispose()
+}
 
-	forward := &math32.Vector3{
+// holdMaterials takes a reference to the materials of every graphic in n and below it
+func holdMaterials(n core.INode) {
+	if g, ok := n.(graphic.IGraphic); ok {
+		materials := g.GetGraphic().Materials()
+		for i := range materials {
+			materials[i].IMaterial().GetMaterial().Incref()
+		}
+	}
+	for _, child := range n.GetNode().Children() {
+		holdMaterials(child)
+	}
+}
+
+// OnMouseDown digs out a sphere around the block under the crosshair with the left button,
+// and builds one onto the face it points at with the right
+func (s *Scene) OnMouseDown(evname string, ev interface{}) {
+	e := ev.(*window.MouseEvent)
+	hit, ok := s.world.Snapshot().Raycast(s.eye())
+	if !ok {
+		return
+	}
+	switch e.Button {
+	case window.MouseButtonLeft:
+		s.edit(Sphere{Center: hit.Node.Position, Radius: 1.5}, Subtract, 0)
+	case window.MouseButtonRight:
+		center := hit.Node.Position.Clone().Add(hit.Normal.Clone().MultiplyScalar(hit.Node.Size))
+		s.edit(Sphere{Center: *center, Radius: 1.5}, Union, 1)
+	default:
+		return
+	}
+	s.buildMeshes()
+}
+
+// edit applies a brush edit to the tree, recording it so it can be undone
+func (s *Scene) edit(shape Shape, mode BrushMode, material int) {
+	s.world.Update(func(root *Node) *Node {
+		return s.history.Edit(root, shape, mode, material)
+	})
+}
+
+// undo reverts the latest edit, or applies the latest undone one again, and rebuilds the meshes if one was
+func (s *Scene) undo(redo bool) {
+	changed := false
+	s.world.Update(func(root *Node) *Node {
+		if redo {
+			root, changed = s.history.Redo(root)
+		} else {
+			root, changed = s.history.Undo(root)
+		}
+		return root
+	})
+	if changed {
+		s.buildMeshes()
+	}
+}
+
+// eye returns the ray along the camera's view in the tree's coordinates, and how far the player can reach
+func (s *Scene) eye() (math32.Vector3, math32.Vector3, float32) {
+	pos := s.cam.Position()
+	return math32.Vector3{X: pos.X - s.offset, Y: pos.Y - s.offset, Z: pos.Z - s.offset}, s.forward(), 64
+}
+
+func (s *Scene) forward() math32.Vector3 {
+	forward := math32.Vector3{
 		X: math32.Cos(s.yaw) * math32.Cos(s.pitch),
 		Y: math32.Sin(s.pitch),
 		Z: math32.Sin(s.yaw) * math32.Cos(s.pitch),
 	}
 	forward.Normalize()
+	return forward
+}
+
+func (s *Scene) Update(renderer *renderer.Renderer, deltaTime time.Duration) {
+	width, height := a.GetFramebufferSize()
+	a.Gls().Viewport(0, 0, int32(width), int32(height))
+	s.cam.SetAspect(float32(width) / float32(height))
+
+	f := s.forward()
+	forward := &f
 	up := &math32.Vector3{X: 0, Y: 1, Z: 0}
 	right := forward.Clone().Cross(up).Normalize()
 	pos := s.cam.Position()
@@ -169,6 +362,36 @@ func (s *Scene) Update(renderer *renderer.Renderer, deltaTime time.Duration) {
 		up,
 	)
 
+	// Rebuild the level of detail mesh, when shown, once the camera has moved a quarter of the first ring
+	// Meshes built before switching to another mesher are dropped
+	showLOD := meshNames()[s.mesher] == lodMesher
+	select {
+	case g := <-s.lodGeoms:
+		s.lodBuilding = false
+		if showLOD {
+			root := core.NewNode()
+			root.Add(graphic.NewMesh(g.geom, s.mat))
+			s.showMesh(root, g.err)
+			if s.lodStale {
+				s.buildLOD()
+			}
+		}
+	default:
+	}
+	if showLOD && pos.DistanceTo(&s.lodEye) > lod.Rings[0]/4 {
+		s.buildLOD()
+	}
+
+	offset := s.offset
+	if hit, ok := s.world.Snapshot().Raycast(s.eye()); ok {
+		p := hit.Node.Position
+		s.highlight.SetPosition(p.X+offset, p.Y+offset, p.Z+offset)
+		s.highlight.SetScale(hit.Node.Size, hit.Node.Size, hit.Node.Size)
+		s.highlight.SetVisible(true)
+	} else {
+		s.highlight.SetVisible(false)
+	}
+
 	a.Gls().Clear(gls.DEPTH_BUFFER_BIT | gls.STENCIL_BUFFER_BIT | gls.COLOR_BUFFER_BIT)
 	if err := renderer.Render(s, s.cam); err != nil {
 		panic(err)
@@ -6,6 +6,7 @@ in vec3 Normal;        // Fragment normal in camera coordinates
 in vec3 WorldPosition; // Fragment position in world coordinates
 in vec3 WorldNormal;   // Fragment normal in world coordinates
 noperspective in vec3 BaryCoord; // Barycentric coordinate of triangle for wireframe shading
+flat in float FaceMaterial;      // Material of the face, 0 for meshes without materials
 uniform int Mode;
 
 #include <lights>
@@ -31,9 +32,22 @@ vec3 triplanarMapping(sampler2D tex, vec3 normal, vec3 position) {
     return (xColor * normalBlend.x + yColor * normalBlend.y + zColor * normalBlend.z);
 }
 
+// Texture i is for material i+1, samplers can only be picked by constant index
+// Meshes without materials use dirt's texture, material 2
+vec3 materialTexture(int material, vec3 normal, vec3 position) {
+  if (material == 1) {
+    return triplanarMapping(MatTexture[0], normal, position);
+  } else if (material == 3) {
+    return triplanarMapping(MatTexture[2], normal, position);
+  } else if (material == 4) {
+    return triplanarMapping(MatTexture[3], normal, position);
+  }
+  return triplanarMapping(MatTexture[1], normal, position);
+}
+
 void main()
 {
-  vec3 matDiffuse = triplanarMapping(MatTexture[0], WorldNormal, WorldPosition);
+  vec3 matDiffuse = materialTexture(int(FaceMaterial + 0.5), WorldNormal, WorldPosition);
 
   vec3 matAmbient = matDiffuse;
 
@@ -1,4 +1,5 @@
 #include <attributes>
+in float VertexMaterial;
 
 // Model uniforms
 uniform mat4 ModelViewMatrix;
@@ -12,10 +13,12 @@ out vec4 vPosition;
 out vec3 vNormal;
 out vec3 vWorldPosition;
 out vec3 vWorldNormal;
+out float vMaterial;
 
 void main() {
     vWorldPosition = VertexPosition;
     vWorldNormal = VertexNormal;
+    vMaterial = VertexMaterial;
     // Transform vertex position to camera coordinates
     vPosition = ModelViewMatrix * vec4(VertexPosition, 1.0);
     // Transform vertex normal to camera coordinates
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/math32"
+)
+
+// SurfaceNetsMesh places one vertex in every cell of the sample grid that the surface passes
+// through, at the average of the cell's edge crossings, which is much cheaper than
+// dual contouring but rounds off sharp features
+func (n *Node) SurfaceNetsMesh(mat *Material) core.INode {
+	root := core.NewNode()
+	m := graphic.NewMesh(SurfaceNetsGeom(n), mat)
+	root.Add(m)
+	return root
+}
+
+// SurfaceNetsGeom builds the geometry of SurfaceNetsMesh for any volume
+func SurfaceNetsGeom(v Volume) geometry.IGeometry {
+	g := &GeometryBuilder{}
+	surfaceNets(g, v)
+	return g.Build()
+}
+
+func (c *Chunk) SurfaceNetsGeom() geometry.IGeometry {
+	return SurfaceNetsGeom(c)
+}
+
+// surfaceNets meshes the volume's samples, each at the center of its cell
+func surfaceNets(b *GeometryBuilder, v Volume) {
+	samples := volumeSamples(v)
+	origin := volumeOrigin(v)
+	density := func(p [3]int) float32 {
+		return samples[p]
+	}
+	edges := make(map[edgeKey]Crossing)
+	crossingEdges(samples, func(e edgeKey) {
+		edges[e] = crossing(density, e)
+	})
+
+	vertices := make(map[[3]int]uint32)
+	vertex := func(c [3]int) uint32 {
+		if i, ok := vertices[c]; ok {
+			return i
+		}
+		var sum math32.Vector3
+		var count float32
+		cellEdges(c, func(e edgeKey) {
+			if c, ok := edges[e]; ok {
+				p := crossingPosition(origin, e, c)
+				sum.Add(&p)
+				count++
+			}
+		})
+		i := b.CurrentTriangleIndex()
+		b.AddVertex(sum.X/count, sum.Y/count, sum.Z/count)
+		vertices[c] = i
+		return i
+	}
+
+	dualQuads(b, edges, func(p [3]int) bool {
+		return density(p) >= isoLevel
+	}, vertex)
+}
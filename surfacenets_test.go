@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestSurfaceNets(t *testing.T) {
+	tree := sphereTree(16, 5)
+	b := new(GeometryBuilder)
+	surfaceNets(b, tree)
+	checkClosed(t, b)
+	for i := 0; i < len(b.positions); i += 3 {
+		p := math32.Vector3{X: b.positions[i] - 8, Y: b.positions[i+1] - 8, Z: b.positions[i+2] - 8}
+		if r := p.Length(); math32.Abs(r-5) > 0.5 {
+			t.Fatalf("vertex %v at radius %.2f, want 5", p, r)
+		}
+	}
+
+	// A lone block becomes a small closed cube around its center
+	c := new(Chunk)
+	c.Set(3, 4, 5, Rock, 1)
+	b = new(GeometryBuilder)
+	surfaceNets(b, c)
+	checkClosed(t, b)
+	if got := len(b.indices) / 3; got != 12 {
+		t.Fatalf("got %d triangles, want 12", got)
+	}
+	for i := 0; i < len(b.positions); i += 3 {
+		p := b.positions[i : i+3]
+		if p[0] < 3 || p[0] > 4 || p[1] < 4 || p[1] > 5 || p[2] < 5 || p[2] > 6 {
+			t.Fatalf("vertex %v outside block", p)
+		}
+	}
+}
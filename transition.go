@@ -0,0 +1,422 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/math32"
+)
+
+// AdaptiveMesh meshes every leaf of the tree as a single cell at its own size, so merged regions
+// get fewer, larger triangles, and contours each leaf against the smaller faces of its neighbors so the seams
+// between differently sized leaves close up
+// It returns the mesh even when some contours could not be closed, with holes where they were left out, and an error
+func (n *Node) AdaptiveMesh(mat *Material) (core.INode, error) {
+	b := new(GeometryBuilder)
+	err := n.adaptive(b, func(n *Node, _ int) bool {
+		return n.Children == [8]*Node{}
+	})
+	root := core.NewNode()
+	g := b.Build()
+	m := graphic.NewMesh(g, mat)
+	root.Add(m)
+	return root, err
+}
+
+// adaptive meshes the cut of the tree made by the nodes for which leaf returns true
+// A node of size s whose minimum cell is c becomes the cube of the sample grid from c to c+s
+//
+// Every face of a cell is contoured from the samples one unit apart along its boundary,
+// so a large face knows exactly where the surface crosses its edges, but would cut straight across
+// its middle where the smaller faces on the other side follow the surface more closely.
+// A cell whose neighbor is split more finely is contoured with the neighbor's faces in place of its own,
+// so both see the same squares between them and every run is shared by the two cells on either side of it.
+// A run that starts and ends on the same side of its square is bent through a vertex of that square's own,
+// so the cells that only meet along the side don't draw the same straight line between its crossings.
+// The faces of the tree's minimum sides are capped, its maximum sides touch empty samples outside the tree.
+// Runs that do not join up into a loop are left out of the mesh, leaving a hole, and reported in the error.
+func (n *Node) adaptive(b *GeometryBuilder, leaf func(n *Node, size int) bool) error {
+	m := &adaptiveMesher{
+		b:         b,
+		density:   n.density,
+		origin:    n.origin(),
+		leaf:      leaf,
+		crossings: make(map[edgeKey]uint32),
+		points:    make(map[[3]int]uint32),
+		sides:     make(map[sideKey]uint32),
+		tilings:   make(map[square][]square),
+	}
+	m.node(n, [3]int{}, int(n.Size))
+	for _, c := range m.cells {
+		m.cell(c.min, c.size)
+	}
+	if m.open > 0 {
+		return fmt.Errorf("adaptive mesh: skipped %d contours that are not closed", m.open)
+	}
+	return nil
+}
+
+type adaptiveMesher struct {
+	b         *GeometryBuilder
+	density   func([3]int) float32
+	origin    math32.Vector3
+	leaf      func(n *Node, size int) bool
+	crossings map[edgeKey]uint32
+	points    map[[3]int]uint32
+	// The vertices splitting runs that start and end on the same side of their square
+	sides map[sideKey]uint32
+	// The leaves' cells, contoured once the tilings of all their faces are known
+	cells []cube
+	// The smaller squares tiling the face between a leaf and a neighbor split more finely
+	tilings map[square][]square
+	// How many contours were skipped for not being closed
+	open int
+}
+
+// A cube of the sample grid with minimum corner min and edges of length size
+type cube struct {
+	min  [3]int
+	size int
+}
+
+// A square of the sample grid perpendicular to axis, with minimum corner min and edges of length size
+type square struct {
+	min  [3]int
+	size int
+	axis int
+}
+
+// A face of a cell, seen from the side its outward normal points to along the square's axis
+type face struct {
+	square
+	side int
+}
+
+// A run of inside samples along the boundary of a square, and the crossings where it starts and ends
+type run struct {
+	square
+	entry, exit edgeKey
+	points      [][3]int
+}
+
+func (m *adaptiveMesher) node(n *Node, min [3]int, size int) {
+	if n == nil || m.leaf(n, size) {
+		m.cells = append(m.cells, cube{min, size})
+		return
+	}
+	h := size / 2
+	for i, child := range n.Children {
+		m.node(child, childMin(min, h, i), h)
+	}
+	for axis := 0; axis < 3; axis++ {
+		bit := 4 >> axis
+		for i := 0; i < 8; i++ {
+			if i&bit == 0 {
+				m.face(n.Children[i], n.Children[i|bit], childMin(min, h, i), childMin(min, h, i|bit), h, axis)
+			}
+		}
+	}
+}
+
+// face finds the tiling of the interface between same sized nodes a and b, with b on the positive side of a
+// along axis, where one is a leaf and the other is split further
+func (m *adaptiveMesher) face(a, b *Node, amin, bmin [3]int, size, axis int) {
+	aLeaf := a == nil || m.leaf(a, size)
+	bLeaf := b == nil || m.leaf(b, size)
+	switch {
+	case aLeaf && bLeaf:
+	case aLeaf:
+		m.tilings[square{min: bmin, size: size, axis: axis}] = m.faces(b, bmin, size, axis, 0)
+	case bLeaf:
+		m.tilings[square{min: bmin, size: size, axis: axis}] = m.faces(a, amin, size, axis, 1)
+	default:
+		h := size / 2
+		bit := 4 >> axis
+		for i := 0; i < 8; i++ {
+			if i&bit == 0 {
+				m.face(a.Children[i|bit], b.Children[i], childMin(amin, h, i|bit), childMin(bmin, h, i), h, axis)
+			}
+		}
+	}
+}
+
+// faces returns the faces of the cells in n that lie on its minimum (end 0) or maximum (end 1) side along axis
+func (m *adaptiveMesher) faces(n *Node, min [3]int, size, axis, end int) []square {
+	if n == nil || m.leaf(n, size) {
+		s := square{min: min, size: size, axis: axis}
+		s.min[axis] += end * size
+		return []square{s}
+	}
+	h := size / 2
+	bit := 4 >> axis
+	var squares []square
+	for i, child := range n.Children {
+		if (i&bit != 0) == (end == 1) {
+			squares = append(squares, m.faces(child, childMin(min, h, i), h, axis, end)...)
+		}
+	}
+	return squares
+}
+
+// cell contours the cube from min to min+size, with each face tiled as its neighbor sees it
+func (m *adaptiveMesher) cell(min [3]int, size int) {
+	var faces []face
+	for axis := 0; axis < 3; axis++ {
+		lo := square{min: min, size: size, axis: axis}
+		hi := lo
+		hi.min[axis] += size
+		faces = append(faces, m.tiled(lo, -1)...)
+		faces = append(faces, m.tiled(hi, 1)...)
+		if min[axis] == 0 {
+			m.cap(lo)
+		}
+	}
+	m.contour(faces)
+}
+
+// tiled returns the faces covering s seen from side, which are s itself unless a neighbor tiles it more finely
+func (m *adaptiveMesher) tiled(s square, side int) []face {
+	tiling, ok := m.tilings[s]
+	if !ok {
+		return []face{{s, side}}
+	}
+	faces := make([]face, len(tiling))
+	for i, t := range tiling {
+		faces[i] = face{t, side}
+	}
+	return faces
+}
+
+// contour joins the runs on the faces of a closed cell into loops, each run ending at a crossing
+// where a run on the adjacent face starts, and fans each loop around its centroid
+// A run ending where no other starts is dropped along with the runs leading to it and counted in m.open
+func (m *adaptiveMesher) contour(faces []face) {
+	next := make(map[edgeKey]run)
+	for _, f := range faces {
+		for _, r := range m.runs(f.square, f.side) {
+			next[r.entry] = r
+		}
+	}
+	for len(next) > 0 {
+		var start edgeKey
+		for e := range next {
+			start = e
+			break
+		}
+		var loop []uint32
+		for e := start; ; {
+			loop = append(loop, m.crossing(e))
+			r, ok := next[e]
+			if !ok {
+				m.open++
+				loop = nil
+				break
+			}
+			if v, ok := m.side(r); ok {
+				loop = append(loop, v)
+			}
+			delete(next, e)
+			if r.exit == start {
+				break
+			}
+			e = r.exit
+		}
+		if loop != nil {
+			m.fan(loop)
+		}
+	}
+}
+
+// cap closes the surface across a face on the minimum side of the tree
+func (m *adaptiveMesher) cap(s square) {
+	runs := m.runs(s, -1)
+	if len(runs) == 0 {
+		boundary := s.boundary(-1)
+		if !m.inside(boundary[0]) {
+			return
+		}
+		var polygon []uint32
+		for _, p := range boundary {
+			polygon = append(polygon, m.point(p))
+		}
+		m.fan(polygon)
+		return
+	}
+	for _, r := range runs {
+		polygon := []uint32{m.crossing(r.entry)}
+		for _, p := range r.points {
+			polygon = append(polygon, m.point(p))
+		}
+		polygon = append(polygon, m.crossing(r.exit))
+		if v, ok := m.side(r); ok {
+			polygon = append(polygon, v)
+		}
+		m.fan(polygon)
+	}
+}
+
+// runs finds the inside runs along the boundary of s seen from side
+// Two inside corners diagonally opposite are always separate runs, whichever cell is looking at the face
+func (m *adaptiveMesher) runs(s square, side int) []run {
+	boundary := s.boundary(side)
+	k := len(boundary)
+	var runs []run
+	for i := range boundary {
+		prev := boundary[(i+k-1)%k]
+		if !m.inside(boundary[i]) || m.inside(prev) {
+			continue
+		}
+		r := run{square: s, entry: unitEdge(prev, boundary[i])}
+		j := i
+		for m.inside(boundary[j%k]) {
+			r.points = append(r.points, boundary[j%k])
+			j++
+		}
+		r.exit = unitEdge(boundary[(j-1)%k], boundary[j%k])
+		runs = append(runs, r)
+	}
+	return runs
+}
+
+// boundary returns the samples one unit apart around s, counter-clockwise seen from side
+func (s square) boundary(side int) [][3]int {
+	u, v := (s.axis+1)%3, (s.axis+2)%3
+	at := func(i, j int) [3]int {
+		p := s.min
+		p[u] += i
+		p[v] += j
+		return p
+	}
+	points := make([][3]int, 0, 4*s.size)
+	for k := 0; k < s.size; k++ {
+		points = append(points, at(k, 0))
+	}
+	for k := 0; k < s.size; k++ {
+		points = append(points, at(s.size, k))
+	}
+	for k := s.size; k > 0; k-- {
+		points = append(points, at(k, s.size))
+	}
+	for k := s.size; k > 0; k-- {
+		points = append(points, at(0, k))
+	}
+	if side < 0 {
+		for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
+		}
+	}
+	return points
+}
+
+func (m *adaptiveMesher) inside(p [3]int) bool {
+	return m.density(p) >= isoLevel
+}
+
+// crossing returns the vertex where the surface cuts edge e
+func (m *adaptiveMesher) crossing(e edgeKey) uint32 {
+	if i, ok := m.crossings[e]; ok {
+		return i
+	}
+	c := interpolate(m.density, e, isoLevel)
+	p := crossingPosition(m.origin, e, c)
+	i := m.b.CurrentTriangleIndex()
+	m.b.AddVertex(p.X, p.Y, p.Z)
+	m.b.AddNormal(c.Normal.X, c.Normal.Y, c.Normal.Z)
+	m.crossings[e] = i
+	return i
+}
+
+// point returns the vertex at sample p, used by caps
+func (m *adaptiveMesher) point(p [3]int) uint32 {
+	if i, ok := m.points[p]; ok {
+		return i
+	}
+	pos := samplePosition(m.origin, p)
+	g := gradient(m.density, p)
+	g.Negate().Normalize()
+	i := m.b.CurrentTriangleIndex()
+	m.b.AddVertex(pos.X, pos.Y, pos.Z)
+	m.b.AddNormal(g.X, g.Y, g.Z)
+	m.points[p] = i
+	return i
+}
+
+// fan triangulates a polygon of existing vertices around a new vertex at its centroid
+func (m *adaptiveMesher) fan(polygon []uint32) {
+	var center, normal math32.Vector3
+	for _, i := range polygon {
+		center.X += m.b.positions[i*3]
+		center.Y += m.b.positions[i*3+1]
+		center.Z += m.b.positions[i*3+2]
+		normal.X += m.b.normals[i*3]
+		normal.Y += m.b.normals[i*3+1]
+		normal.Z += m.b.normals[i*3+2]
+	}
+	center.DivideScalar(float32(len(polygon)))
+	normal.Normalize()
+	c := m.b.CurrentTriangleIndex()
+	m.b.AddVertex(center.X, center.Y, center.Z)
+	m.b.AddNormal(normal.X, normal.Y, normal.Z)
+	for i := range polygon {
+		m.b.AddTriangle(c, polygon[i], polygon[(i+1)%len(polygon)])
+	}
+}
+
+// side returns a vertex halfway between the crossings of r, if they are on the same side of its square
+// The straight line between them then runs along the side, which other squares around it share, and so could
+// be drawn by every cell around the side; splitting it with a vertex of the square's own keeps it to the two cells
+// on either side of the square, which see the run the other way round
+func (m *adaptiveMesher) side(r run) (uint32, bool) {
+	a, b := r.entry, r.exit
+	if a.axis != b.axis || a.cell[(a.axis+1)%3] != b.cell[(a.axis+1)%3] || a.cell[(a.axis+2)%3] != b.cell[(a.axis+2)%3] {
+		return 0, false
+	}
+	if b.cell[b.axis] < a.cell[a.axis] {
+		a, b = b, a
+	}
+	k := sideKey{r.square, a, b}
+	if i, ok := m.sides[k]; ok {
+		return i, true
+	}
+	var p, normal math32.Vector3
+	for _, v := range []uint32{m.crossing(a), m.crossing(b)} {
+		p.X += m.b.positions[v*3]
+		p.Y += m.b.positions[v*3+1]
+		p.Z += m.b.positions[v*3+2]
+		normal.X += m.b.normals[v*3]
+		normal.Y += m.b.normals[v*3+1]
+		normal.Z += m.b.normals[v*3+2]
+	}
+	p.DivideScalar(2)
+	normal.Normalize()
+	i := m.b.CurrentTriangleIndex()
+	m.b.AddVertex(p.X, p.Y, p.Z)
+	m.b.AddNormal(normal.X, normal.Y, normal.Z)
+	m.sides[k] = i
+	return i, true
+}
+
+// A run's square and its crossings, lowest first
+type sideKey struct {
+	square
+	a, b edgeKey
+}
+
+// unitEdge returns the key of the edge between neighboring samples p and q
+func unitEdge(p, q [3]int) edgeKey {
+	for axis := 0; axis < 3; axis++ {
+		if p[axis] < q[axis] {
+			return edgeKey{cell: p, axis: axis}
+		}
+		if q[axis] < p[axis] {
+			return edgeKey{cell: q, axis: axis}
+		}
+	}
+	panic("unitEdge: samples are not neighbors")
+}
+
+// childMin returns the minimum cell of child i of the node with minimum cell min and children of size h
+func childMin(min [3]int, h, i int) [3]int {
+	return [3]int{min[0] + (i>>2&1)*h, min[1] + (i>>1&1)*h, min[2] + (i&1)*h}
+}
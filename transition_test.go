@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestAdaptiveSphere(t *testing.T) {
+	tree := sphereTree(16, 5)
+	for _, test := range []struct {
+		name      string
+		leaf      func(n *Node, size int) bool
+		tolerance float32
+	}{
+		{"leaves", func(n *Node, _ int) bool {
+			return n.Children == [8]*Node{}
+		}, 0.5},
+		// The half of the sphere below x = 8 is meshed four times coarser
+		{"coarse half", func(n *Node, size int) bool {
+			return n.Children == [8]*Node{} || (size <= 4 && n.Position.X < 8)
+		}, 1.5},
+		{"coarse octant", func(n *Node, size int) bool {
+			return n.Children == [8]*Node{} || (size <= 2 && n.Position.X < 8 && n.Position.Y < 8 && n.Position.Z < 8)
+		}, 1},
+	} {
+		b := new(GeometryBuilder)
+		if err := tree.adaptive(b, test.leaf); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(b.indices) == 0 {
+			t.Fatalf("%s: no triangles", test.name)
+		}
+		checkClosed(t, b)
+		for i := 0; i < len(b.positions); i += 3 {
+			p := math32.Vector3{X: b.positions[i] - 8, Y: b.positions[i+1] - 8, Z: b.positions[i+2] - 8}
+			if r := p.Length(); math32.Abs(r-5) > test.tolerance {
+				t.Fatalf("%s: vertex %v at radius %.2f, want 5", test.name, p, r)
+			}
+		}
+		// Outward facing triangles enclose a positive volume close to the ball's,
+		// off by at most the surface area times the distance vertices stray from it
+		if v := volume(b); math32.Abs(v-4.0/3*math32.Pi*125) > 4*math32.Pi*25*test.tolerance {
+			t.Fatalf("%s: mesh encloses volume %.1f, want %.1f", test.name, v, 4.0/3*math32.Pi*125)
+		}
+	}
+}
+
+// volume returns the signed volume enclosed by a closed mesh
+func volume(b *GeometryBuilder) float32 {
+	var v float32
+	for i := 0; i < len(b.indices); i += 3 {
+		var p [3]math32.Vector3
+		for j := range p {
+			k := b.indices[i+j] * 3
+			p[j] = math32.Vector3{X: b.positions[k], Y: b.positions[k+1], Z: b.positions[k+2]}
+		}
+		v += p[0].Dot(p[1].Cross(&p[2])) / 6
+	}
+	return v
+}
+
+func TestAdaptiveCapsMergedBlock(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 4, Y: 4, Z: 4}, 8)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			for z := 0; z < 4; z++ {
+				tree.Set(x, y, z, 1, 1)
+			}
+		}
+	}
+	tree.merge()
+	if node := tree.leafAt([3]int{0, 0, 0}); node.Size != 4 {
+		t.Fatalf("block was not merged, got size %.0f", node.Size)
+	}
+	b := new(GeometryBuilder)
+	if err := tree.adaptive(b, func(n *Node, _ int) bool {
+		return n.Children == [8]*Node{}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	checkClosed(t, b)
+	for i := 0; i < len(b.positions); i++ {
+		if c := b.positions[i]; c < 0.5 || c > 4 {
+			t.Fatalf("vertex %v outside block", b.positions[i-i%3:i-i%3+3])
+		}
+	}
+}
+
+func TestAdaptiveSkipsOpenContour(t *testing.T) {
+	tree := NewTree(nil, math32.Vector3{X: 2, Y: 2, Z: 2}, 4)
+	tree.Set(1, 1, 1, 1, 1)
+	b := new(GeometryBuilder)
+	m := &adaptiveMesher{
+		b:         b,
+		density:   tree.density,
+		origin:    tree.origin(),
+		crossings: make(map[edgeKey]uint32),
+		points:    make(map[[3]int]uint32),
+		sides:     make(map[sideKey]uint32),
+	}
+	// A single face of the cell around the inside sample has a run with nowhere to go on from its exit
+	m.contour([]face{{square{min: [3]int{1, 1, 1}, size: 1, axis: 0}, -1}})
+	if m.open != 1 {
+		t.Fatalf("counted %d open contours, want 1", m.open)
+	}
+	if len(b.indices) != 0 {
+		t.Fatalf("open contour drew %d triangles", len(b.indices)/3)
+	}
+}
+
+func TestAdaptiveRandom(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		t.Run(fmt.Sprint(seed), func(t *testing.T) {
+			r := rand.New(rand.NewSource(seed))
+			tree := NewTree(nil, math32.Vector3{X: 8, Y: 8, Z: 8}, 16)
+			fill := r.Float32()
+			for x := float32(0.5); x < 16; x++ {
+				for y := float32(0.5); y < 16; y++ {
+					for z := float32(0.5); z < 16; z++ {
+						if r.Float32() < fill {
+							node := tree.At(x, y, z)
+							node.Material = 1 + r.Intn(3)
+							node.Density = r.Float32()
+						}
+					}
+				}
+			}
+			if r.Intn(2) == 0 {
+				tree.Merge(ErrorMetric{r.Float32() / 2})
+			}
+			// Cutting the tree above its leaves as well gives neighbors that differ in size by more than one level
+			cut := 1 << uint(r.Intn(4))
+			b := new(GeometryBuilder)
+			err := tree.adaptive(b, func(n *Node, size int) bool {
+				return n.Children == [8]*Node{} || size <= cut
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkClosed(t, b)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import "github.com/g3n/engine/math32"
+
+// A Volume is a box of unit cells with a material and a density each, however they are stored
+// Cell (x, y, z) is the unit cube that many units from the minimum corner of Bounds, and the meshers
+// taking a Volume place their geometry in the same space, so Chunk and Node get every one of them
+type Volume interface {
+	// Bounds returns the box the cells fill, whose sides are whole numbers of cells
+	Bounds() math32.Box3
+	// Get returns the material and density of cell (x, y, z), either of which is zero for an empty cell
+	// Outside its bounds a volume may see the cells of its neighbors, and gives zeros where it knows of none
+	Get(x, y, z int) (material int, density float32)
+	// Cells calls fn with every non-empty cell
+	Cells(fn func(p [3]int, material int, density float32))
+}
+
+// volumeSize returns the number of cells along each axis of v
+func volumeSize(v Volume) [3]int {
+	b := v.Bounds()
+	return [3]int{int(b.Max.X - b.Min.X), int(b.Max.Y - b.Min.Y), int(b.Max.Z - b.Min.Z)}
+}
+
+// volumeOrigin returns the center of cell (0, 0, 0) of v
+func volumeOrigin(v Volume) math32.Vector3 {
+	b := v.Bounds()
+	return *b.Min.AddScalar(0.5)
+}
+
+// volumeMaterial returns the material of cell p of v as a block, zero unless it is inside the surface
+func volumeMaterial(v Volume, p [3]int) int {
+	material, density := v.Get(p[0], p[1], p[2])
+	if density < isoLevel {
+		return 0
+	}
+	return material
+}
+
+// volumeSamples returns the density of every non-empty cell of v keyed by its coordinates
+func volumeSamples(v Volume) map[[3]int]float32 {
+	s := make(map[[3]int]float32)
+	v.Cells(func(p [3]int, _ int, density float32) {
+		s[p] = density
+	})
+	return s
+}
+
+// VolumeTree converts v to a tree with the same cells, merged as far as it goes without losing anything,
+// or returns v if it is a tree
+// The tree's size is the power of two covering the longest side of v, with cell (0, 0, 0) in the same place
+func VolumeTree(v Volume) *Node {
+	if n, ok := v.(*Node); ok {
+		return n
+	}
+	b := v.Bounds()
+	size := volumeSize(v)
+	s := 1
+	for s < size[0] || s < size[1] || s < size[2] {
+		s *= 2
+	}
+	h := float32(s) / 2
+	n := NewTree(nil, math32.Vector3{X: b.Min.X + h, Y: b.Min.Y + h, Z: b.Min.Z + h}, float32(s))
+	o := n.origin()
+	v.Cells(func(p [3]int, material int, density float32) {
+		node := n.At(o.X+float32(p[0]), o.Y+float32(p[1]), o.Z+float32(p[2]))
+		node.Material = material
+		node.Density = density
+	})
+	n.updateHermiteRange([3]int{}, [3]int{s - 1, s - 1, s - 1})
+	n.merge()
+	return n
+}
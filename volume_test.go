@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/g3n/engine/geometry"
+)
+
+func TestVolumeMeshers(t *testing.T) {
+	rand.Seed(2)
+	c := NewChunk()
+	c.Set(10, 10, 10, Rock, 0.75)
+	tree := c.Tree()
+	for _, v := range []Volume{c, tree} {
+		if size := volumeSize(v); size != [3]int{ChunkSize, ChunkSize, ChunkSize} {
+			t.Fatalf("%T has size %v", v, size)
+		}
+	}
+
+	// The same cells mesh the same whatever stores them
+	for name, mesh := range map[string]func(Volume) geometry.IGeometry{
+		"simple":         SimpleGeom,
+		"culled":         CulledGeom,
+		"greedy":         GreedyGeom,
+		"dual contour":   DualContourGeom,
+		"surface nets":   SurfaceNetsGeom,
+		"marching cubes": func(v Volume) geometry.IGeometry { return MarchingCubesGeom(v, isoLevel) },
+	} {
+		a, b := mesh(c).GetGeometry(), mesh(tree).GetGeometry()
+		if len(a.Indices()) == 0 || len(a.Indices()) != len(b.Indices()) {
+			t.Fatalf("%s mesh of the chunk has %d indices, of the tree %d", name, len(a.Indices()), len(b.Indices()))
+		}
+		ab, bb := a.BoundingBox(), b.BoundingBox()
+		if !ab.Equals(&bb) {
+			t.Fatalf("%s mesh of the chunk spans %v, of the tree %v", name, ab, bb)
+		}
+	}
+
+	// A lone block is drawn as its six faces, not as the sides of the chunk around it
+	c = new(Chunk)
+	c.Set(0, 0, 0, Rock, 1)
+	if got := len(GreedyGeom(c).GetGeometry().Indices()) / 6; got != 6 {
+		t.Fatalf("greedy mesh of one block has %d faces, want 6", got)
+	}
+}